@@ -1,8 +1,10 @@
 import os
+import queue
 import signal
 import sys
 import json
 import socket
+import threading
 import data_preparation
 import net
 import param
@@ -15,10 +17,13 @@ class Request(object):
         
 
 class Response(object):
-    def __init__(self,trained=None,key=None,prediction=None,loss=None,error=""):
+    def __init__(self,trained=None,key=None,prediction=None,loss=None,error="",lower=None,upper=None,job_id=None):
+        self.job_id = job_id
         self.trained = trained
         self.key = key
         self.prediction = prediction
+        self.lower = lower
+        self.upper = upper
         self.loss = loss
         self.error = error
         
@@ -31,27 +36,38 @@ def check_status(request):
         status |= param.STATUS_PREDICT
     return status
 
+# param is global to the networks, so the trainings and predictions take turns,
+# each sets the parameters of its own request under the lock
+torch_lock = threading.Lock()
+
 def handle(request):
-    rsp = Response(False,None,None)
+    """
+        must be called with torch_lock held
+    """
+    # the job id is echoed, the go side routes the result of a training to its predictor by it
+    rsp = Response(False,None,None,job_id=getattr(request,'job_id',None))
     # 进行预测，接收到数据处理之后，需要传回响应
-    if hasattr(request,'epochs'):
-        param.epochs = request.epochs
-    if hasattr(request,'n_layers'):
-        param.n_layers = request.n_layers
-    if hasattr(request,"batch_size"):
-        param.batch_size = request.batch_size
+    for name in ("look_back", "look_forward", "epochs", "n_layers", "batch_size"):
+        if getattr(request, name, None):
+            setattr(param, name, getattr(request, name))
+    # GRU or LSTM, both are served over the same protocol
+    model_type = getattr(request,'model_type',None) or param.model_type
     if check_status(request) & param.STATUS_PREDICT:
         metrics = []
         for index, val in enumerate(request.predict_history):
             metrics.append(val)
         try:
-            out = net.predict(metrics,request.key)
+            out, lower, upper = net.predict(metrics,request.key,model_type=model_type,
+                                            samples=getattr(request,'samples',1),
+                                            confidence=getattr(request,'confidence',0.95))
         except Exception as e:
-            rsp.error = e.with_traceback(None)
+            rsp.error = str(e)
             return rsp
         rsp.trained = True
         rsp.key = request.key
         rsp.prediction = out
+        rsp.lower = lower
+        rsp.upper = upper
         return rsp
     # 训练，接收到数据后进行处理，传回响应的地址并不是数据传递过来时的地址
     if check_status(request) & param.STATUS_TRAIN:
@@ -61,9 +77,9 @@ def handle(request):
         train_loader = data_preparation.train_data_prepare(metrics)
         client = SocketClient(request.resp_recv_address)
         try:
-            loss = net.train(train_loader, request.key)
+            loss = net.train(train_loader, request.key, model_type=model_type)
         except Exception as e:
-            rsp.error = e.with_traceback(None)
+            rsp.error = str(e)
             client.send(json.dumps(rsp.__dict__))
             return
         rsp.trained = True
@@ -94,8 +110,14 @@ class SocketServer:
         self.sock = socket.socket(socket_family, socket_type)
         self.sock.setsockopt(socket.SOL_SOCKET, socket.SO_REUSEADDR, 1)
         self.sock.bind(param.socket_address)
-        self.sock.listen(1)
+        self.sock.listen(16)
+        # the trainings run one at a time off the accept loop, so the health checks are answered
+        # while a model trains
+        self.trainings = queue.Queue()
+        threading.Thread(target=self._train_loop, daemon=True).start()
         print(f"listening on '{param.socket_address}'.")
+        # the readiness handshake of the supervisor in the operator
+        print("READY", flush=True)
 
         # register signal handler
         signal.signal(signal.SIGINT, self._signal_handler)
@@ -112,11 +134,40 @@ class SocketServer:
                 data += chunk
                 if data.endswith(b"\n"):
                     break
+            # the health checks connect and close without a request
+            if not data:
+                connection.close()
+                continue
             print(f"recv data from client '{client_address}': {data.decode()}")
-            resp = handle(Request(data.decode().removesuffix("\n")))
+            request = Request(data.decode().removesuffix("\n"))
+            # the result of a training is sent to resp_recv_address, nothing is answered here
+            if check_status(request) == param.STATUS_TRAIN:
+                self.trainings.put(request)
+                connection.shutdown(socket.SHUT_WR)
+                continue
+            # a prediction waits for the training running, the accept loop goes on meanwhile
+            threading.Thread(target=self._predict, args=(connection, request), daemon=True).start()
+
+    def _predict(self, connection, request):
+        try:
+            with torch_lock:
+                resp = handle(request)
             if resp:
                 connection.sendall(json.dumps(resp.__dict__).encode())
             connection.shutdown(socket.SHUT_WR)
+        except Exception as e:
+            print(f"predict {getattr(request,'key',None)} failed: {e}")
+        finally:
+            connection.close()
+
+    def _train_loop(self):
+        while True:
+            request = self.trainings.get()
+            try:
+                with torch_lock:
+                    handle(request)
+            except Exception as e:
+                print(f"train {getattr(request,'key',None)} failed: {e}")
 
     def _signal_handler(self, signum, frame):
         print(f"\nreceived signal {signum}, exiting...")
@@ -129,5 +180,6 @@ class SocketServer:
         os.system('rm -rf {}'.format(param.socket_address))
 
 if __name__ == "__main__":
+    param.socket_address = os.environ.get("AOM_MODEL_SOCKET", param.socket_address)
     socket_server_obj = SocketServer()
     socket_server_obj.wait_and_deal_client_connect()
@@ -1,3 +1,5 @@
+import hashlib
+import os
 import time
 
 import numpy as np
@@ -51,6 +53,14 @@ class LSTMNet(nn.Module):
         return hidden
 
 
+def model_path(key):
+    """
+        the file the model of key is saved in, keys hold any character, e.g. / in queries,
+        so the name is a hash of the key and never leaves param.model_dir
+    """
+    return os.path.join(param.model_dir, hashlib.sha256(key.encode()).hexdigest() + '.pt')
+
+
 def train(train_loader,metric_type, learn_rate=0.001, hidden_dim=256, model_type="GRU"):
     # Setting common hyperparameters
     global avg_loss
@@ -99,7 +109,7 @@ def train(train_loader,metric_type, learn_rate=0.001, hidden_dim=256, model_type
         print("Time Elapsed for Epoch: {} seconds".format(str(current_time - start_time)))
         epoch_times.append(current_time - start_time)
     print("Total Training Time: {} seconds".format(str(sum(epoch_times))))
-    torch.save(model.state_dict(), '{}.pt'.format(metric_type))
+    torch.save(model.state_dict(), model_path(metric_type))
     return avg_loss / len(train_loader)#model
 
 
@@ -122,7 +132,12 @@ def evaluate(model, test_x, test_y, label_scalers):
     print("sMAPE: {}%".format(sMAPE * 100))
     return outputs, targets, sMAPE
 
-def predict(metrics,metric_type,model_type="GRU",hidden_dim = 256):
+def predict(metrics,metric_type,model_type="GRU",hidden_dim = 256,samples=1,confidence=0.95):
+    """
+        returns the prediction and its lower and upper bounds at the confidence level,
+        the bounds are the quantiles of samples forward passes with dropout on (monte carlo dropout),
+        they are None when samples is 1
+    """
     norm_trans = data_preparation.data_transformer(metrics)
     metrics = norm_trans.normalize()
     metrics = np.array(metrics)
@@ -138,8 +153,21 @@ def predict(metrics,metric_type,model_type="GRU",hidden_dim = 256):
     else:
         model = LSTMNet(input_dim, hidden_dim, output_dim, n_layers)
     model.to(param.device)
-    model.load_state_dict(torch.load('{}.pt'.format(metric_type)))
-    h = model.init_hidden(metrics.shape[0])
-    out,_ = model(torch.from_numpy(metrics).to(param.device).float(),h)
-    result = out.tolist()[0]
-    return norm_trans.denormalize(result)
\ No newline at end of file
+    model.load_state_dict(torch.load(model_path(metric_type)))
+    inputs = torch.from_numpy(metrics).to(param.device).float()
+    if samples <= 1:
+        model.eval()
+        with torch.no_grad():
+            out,_ = model(inputs,model.init_hidden(metrics.shape[0]))
+        return norm_trans.denormalize(out.tolist()[0]), None, None
+    # dropout stays on in train mode, each pass samples another thinned network
+    model.train()
+    outs = []
+    with torch.no_grad():
+        for _ in range(samples):
+            out,_ = model(inputs,model.init_hidden(metrics.shape[0]))
+            outs.append(out.cpu().numpy()[0])
+    outs = np.array(outs)
+    lower = np.quantile(outs, (1 - confidence) / 2, axis=0)
+    upper = np.quantile(outs, (1 + confidence) / 2, axis=0)
+    return norm_trans.denormalize(outs.mean(axis=0)), norm_trans.denormalize(lower), norm_trans.denormalize(upper)
@@ -1,3 +1,5 @@
+import os
+
 import torch
 
 model_type ='GRU'
@@ -12,4 +14,7 @@ STATUS_PREDICT = 2
 
 device = torch.device("cuda") if torch.cuda.is_available() else torch.device("cpu")
 
-socket_address = '/tmp/uds_socket'
\ No newline at end of file
+socket_address = '/tmp/uds_socket'
+
+# the trained models are saved here
+model_dir = os.environ.get("AOM_MODEL_DIR", ".")
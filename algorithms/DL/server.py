@@ -0,0 +1,186 @@
+# serves the GRU and LSTM models over the v1 model-server protocol, see predictor/modelserver/protocol.go
+# usage: server.py [host:port | unix:///path]
+import json
+import os
+import socketserver
+import sys
+import threading
+from datetime import datetime, timezone
+from http.server import BaseHTTPRequestHandler, HTTPServer
+from urllib.parse import unquote
+
+import data_preparation
+import net
+import param
+
+MODELS_PATH = "/v1/models"
+
+
+class NotFound(Exception):
+    pass
+
+
+class NotTrained(Exception):
+    pass
+
+
+class Models(object):
+    def __init__(self):
+        self.statuses = {}
+        self.lock = threading.Lock()
+        # param is global to the networks, so the trainings and predictions take turns
+        self.torch_lock = threading.Lock()
+
+    def status(self, key):
+        with self.lock:
+            if key not in self.statuses:
+                raise NotFound(key)
+            return dict(self.statuses[key])
+
+    def set_status(self, key, model_type, state, loss=0, error=""):
+        with self.lock:
+            self.statuses[key] = {
+                "key": key,
+                "model_type": model_type,
+                "state": state,
+                "loss": loss,
+                "error": error,
+                "updated_at": datetime.now(timezone.utc).isoformat(),
+            }
+            return dict(self.statuses[key])
+
+    def list(self):
+        with self.lock:
+            return [dict(s) for s in self.statuses.values()]
+
+    def delete(self, key):
+        with self.lock:
+            if key not in self.statuses:
+                raise NotFound(key)
+            del self.statuses[key]
+        path = net.model_path(key)
+        if os.path.exists(path):
+            os.remove(path)
+
+    def train(self, key, req):
+        model_type = req.get("model_type") or param.model_type
+        status = self.set_status(key, model_type, "training")
+        threading.Thread(target=self._train, args=(key, model_type, req), daemon=True).start()
+        return status
+
+    def _train(self, key, model_type, req):
+        try:
+            with self.torch_lock:
+                self._set_params(req)
+                train_loader = data_preparation.train_data_prepare(req["history"])
+                loss = net.train(train_loader, key, model_type=model_type)
+        except Exception as e:
+            self.set_status(key, model_type, "failed", error=str(e))
+            return
+        self.set_status(key, model_type, "ready", loss=loss)
+
+    def predict(self, key, req):
+        status = self.status(key)
+        if status["state"] != "ready":
+            raise NotTrained(key)
+        with self.torch_lock:
+            param.look_back = len(req["history"])
+            param.look_forward = req["look_forward"]
+            out, lower, upper = net.predict(req["history"], key, model_type=status["model_type"],
+                                            samples=req.get("samples", 1) or 1,
+                                            confidence=req.get("confidence", 0.95) or 0.95)
+        return {"prediction": out, "lower": lower, "upper": upper, "loss": status["loss"]}
+
+    @staticmethod
+    def _set_params(req):
+        param.look_back = req["look_back"]
+        param.look_forward = req["look_forward"]
+        for name in ("batch_size", "epochs", "n_layers"):
+            if req.get(name):
+                setattr(param, name, req[name])
+
+
+models = Models()
+
+
+class Handler(BaseHTTPRequestHandler):
+    def _respond(self, code, body=None):
+        data = b"" if body is None else json.dumps(body).encode()
+        self.send_response(code)
+        if body is not None:
+            self.send_header("Content-Type", "application/json")
+        self.send_header("Content-Length", str(len(data)))
+        self.end_headers()
+        self.wfile.write(data)
+
+    def _route(self):
+        if self.path == MODELS_PATH:
+            return None, None
+        if not self.path.startswith(MODELS_PATH + "/"):
+            raise NotFound(self.path)
+        parts = self.path[len(MODELS_PATH) + 1:].split("/")
+        if len(parts) > 2:
+            raise NotFound(self.path)
+        return unquote(parts[0]), parts[1] if len(parts) == 2 else None
+
+    def _handle(self, method):
+        try:
+            key, action = self._route()
+            if key is None and method == "GET":
+                return self._respond(200, models.list())
+            if key is not None and action is None and method == "GET":
+                return self._respond(200, models.status(key))
+            if key is not None and action is None and method == "DELETE":
+                models.delete(key)
+                return self._respond(204)
+            if key is not None and method == "POST" and action in ("train", "predict"):
+                req = json.loads(self.rfile.read(int(self.headers.get("Content-Length", 0))))
+                if action == "train":
+                    return self._respond(202, models.train(key, req))
+                return self._respond(200, models.predict(key, req))
+            raise NotFound(self.path)
+        except NotFound as e:
+            self._respond(404, {"error": "model not found: {}".format(e)})
+        except NotTrained as e:
+            self._respond(409, {"error": "model not trained: {}".format(e)})
+        except Exception as e:
+            self._respond(500, {"error": str(e)})
+
+    def do_GET(self):
+        self._handle("GET")
+
+    def do_POST(self):
+        self._handle("POST")
+
+    def do_DELETE(self):
+        self._handle("DELETE")
+
+
+class ThreadingHTTPServer(socketserver.ThreadingMixIn, HTTPServer):
+    daemon_threads = True
+
+
+class ThreadingUnixHTTPServer(socketserver.ThreadingMixIn, socketserver.UnixStreamServer):
+    daemon_threads = True
+
+    def get_request(self):
+        request, _ = super().get_request()
+        # BaseHTTPRequestHandler logs the client address as a host and port
+        return request, ("unix", 0)
+
+
+def serve(address):
+    if address.startswith("unix://") or address.startswith("/"):
+        path = address[len("unix://"):] if address.startswith("unix://") else address
+        if os.path.exists(path):
+            os.remove(path)
+        server = ThreadingUnixHTTPServer(path, Handler)
+    else:
+        host, port = address.rsplit(":", 1)
+        server = ThreadingHTTPServer((host, int(port)), Handler)
+    print("serving the v1 model-server protocol on '{}'".format(address))
+    server.serve_forever()
+
+
+if __name__ == "__main__":
+    serve(sys.argv[1] if len(sys.argv) > 1 else "127.0.0.1:8500")
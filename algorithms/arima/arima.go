@@ -0,0 +1,340 @@
+package arima
+
+import (
+	"errors"
+	"fmt"
+	"github.com/LL-res/AOM/algorithms/optimize"
+	"github.com/LL-res/AOM/common/errs"
+	"math"
+)
+
+// Order of a seasonal ARIMA(p,d,q)(P,D,Q)s model, the seasonal terms are left out when S is 0
+type Order struct {
+	P, D, Q int
+	// the seasonal orders, in seasons of S points
+	SP, SD, SQ int
+	S          int
+}
+
+func (o Order) String() string {
+	if o.S == 0 {
+		return fmt.Sprintf("ARIMA(%d,%d,%d)", o.P, o.D, o.Q)
+	}
+	return fmt.Sprintf("ARIMA(%d,%d,%d)(%d,%d,%d)%d", o.P, o.D, o.Q, o.SP, o.SD, o.SQ, o.S)
+}
+
+func (o Order) validate() error {
+	if o.P < 0 || o.D < 0 || o.Q < 0 || o.SP < 0 || o.SD < 0 || o.SQ < 0 || o.S < 0 {
+		return fmt.Errorf("%s: orders must not be negative", o)
+	}
+	if o.S == 1 || (o.S == 0 && o.SP+o.SD+o.SQ != 0) {
+		return fmt.Errorf("%s: seasonal terms need a season of at least 2 points", o)
+	}
+	return nil
+}
+
+// the lags of the differences, the regular ones first
+func (o Order) lags() []int {
+	res := make([]int, 0, o.D+o.SD)
+	for i := 0; i < o.D; i++ {
+		res = append(res, 1)
+	}
+	for i := 0; i < o.SD; i++ {
+		res = append(res, o.S)
+	}
+	return res
+}
+
+// the largest lag of the autoregressive polynomial
+func (o Order) arLag() int {
+	return o.P + o.SP*o.S
+}
+
+// Model is a fitted ARIMA model
+type Model struct {
+	Order Order
+	// the coefficients of the regular and seasonal autoregressive and moving average terms
+	AR, MA, SAR, SMA []float64
+	// Mean of the differenced series, the drift when it is differenced
+	Mean float64
+	// Sigma2 is the variance of the residuals
+	Sigma2 float64
+	// the number of residuals the fit is conditioned on
+	n int
+}
+
+// Fit estimates the coefficients by conditional sum of squares
+func Fit(series []float64, order Order) (*Model, error) {
+	return fit(series, order, 0)
+}
+
+// fit conditions on the first start differenced points at least, so that fits of different orders are comparable
+func fit(series []float64, order Order, start int) (*Model, error) {
+	if err := order.validate(); err != nil {
+		return nil, err
+	}
+	w := differenced(series, order.lags())
+	if lag := order.arLag(); lag > start {
+		start = lag
+	}
+	k := order.P + order.Q + order.SP + order.SQ
+	// the residuals must outnumber the coefficients, the mean and the variance
+	if len(w)-start <= k+2 {
+		return nil, errs.NO_SUFFICENT_DATA
+	}
+	m := &Model{Order: order, Mean: mean(w)}
+	z := make([]float64, len(w))
+	for i, v := range w {
+		z[i] = v - m.Mean
+	}
+	css := func(x []float64) float64 {
+		candidate := *m
+		candidate.set(x)
+		if !candidate.admissible() {
+			return math.Inf(1)
+		}
+		e := candidate.residuals(z, start)
+		sum := 0.0
+		for _, v := range e[start:] {
+			sum += v * v
+		}
+		return sum / float64(len(z)-start)
+	}
+	x0 := make([]float64, k)
+	res := optimize.NelderMead(css, x0, optimize.Settings{Tol: 1e-10})
+	if math.IsInf(res.F, 1) {
+		return nil, errors.New("no stationary and invertible coefficients found")
+	}
+	m.set(res.X)
+	m.Sigma2 = res.F
+	m.n = len(z) - start
+	return m, nil
+}
+
+// set spreads x over the coefficients
+func (m *Model) set(x []float64) {
+	o := m.Order
+	m.AR, x = x[:o.P], x[o.P:]
+	m.MA, x = x[:o.Q], x[o.Q:]
+	m.SAR, x = x[:o.SP], x[o.SP:]
+	m.SMA = x[:o.SQ]
+}
+
+// admissible tells whether the autoregressive polynomials are stationary and the moving average ones invertible
+func (m *Model) admissible() bool {
+	return stable(m.AR, -1) && stable(m.SAR, -1) && stable(m.MA, 1) && stable(m.SMA, 1)
+}
+
+// polynomials returns the coefficients a and b of z[t] = sum a[k] z[t-k] + e[t] + sum b[k] e[t-k],
+// the seasonal and regular polynomials multiplied out, index 0 unused
+func (m *Model) polynomials() ([]float64, []float64) {
+	s := m.Order.S
+	ar := multiply(lagPolynomial(m.AR, 1, -1), lagPolynomial(m.SAR, s, -1))
+	ma := multiply(lagPolynomial(m.MA, 1, 1), lagPolynomial(m.SMA, s, 1))
+	for i := range ar {
+		ar[i] = -ar[i]
+	}
+	return ar, ma
+}
+
+// residuals of the demeaned differenced series, the ones before start are taken as 0
+func (m *Model) residuals(z []float64, start int) []float64 {
+	a, b := m.polynomials()
+	e := make([]float64, len(z))
+	for t := start; t < len(z); t++ {
+		pred := 0.0
+		for k := 1; k < len(a); k++ {
+			if t-k >= 0 {
+				pred += a[k] * z[t-k]
+			}
+		}
+		for k := 1; k < len(b); k++ {
+			if t-k >= 0 {
+				pred += b[k] * e[t-k]
+			}
+		}
+		e[t] = z[t] - pred
+	}
+	return e
+}
+
+// AIC of the fit, approximated with the conditional sum of squares
+func (m *Model) AIC() float64 {
+	// the coefficients, the mean and the variance
+	k := len(m.AR) + len(m.MA) + len(m.SAR) + len(m.SMA) + 2
+	return float64(m.n)*math.Log(m.Sigma2) + 2*float64(k)
+}
+
+// Forecast predicts the steps points following series with the fitted coefficients
+func (m *Model) Forecast(series []float64, steps int) ([]float64, error) {
+	lags := m.Order.lags()
+	stages := make([][]float64, 0, len(lags)+1)
+	stages = append(stages, series)
+	for _, lag := range lags {
+		stages = append(stages, difference(stages[len(stages)-1], lag))
+	}
+	w := stages[len(stages)-1]
+	start := m.Order.arLag()
+	if len(w) <= start {
+		return nil, errs.NO_SUFFICENT_DATA
+	}
+	z := make([]float64, len(w), len(w)+steps)
+	for i, v := range w {
+		z[i] = v - m.Mean
+	}
+	e := m.residuals(z, start)
+	a, b := m.polynomials()
+	for h := 0; h < steps; h++ {
+		t := len(z)
+		pred := 0.0
+		for k := 1; k < len(a); k++ {
+			pred += a[k] * z[t-k]
+		}
+		// the future errors are expected to be 0
+		for k := 1; k < len(b); k++ {
+			if t-k < len(e) {
+				pred += b[k] * e[t-k]
+			}
+		}
+		z = append(z, pred)
+	}
+	forecast := make([]float64, steps)
+	for i := range forecast {
+		forecast[i] = z[len(w)+i] + m.Mean
+	}
+	// integrate back through the differences, the last one first
+	for i := len(lags) - 1; i >= 0; i-- {
+		prev := append(append([]float64(nil), stages[i]...), make([]float64, steps)...)
+		n := len(stages[i])
+		for h := 0; h < steps; h++ {
+			prev[n+h] = forecast[h] + prev[n+h-lags[i]]
+		}
+		forecast = prev[n:]
+	}
+	return forecast, nil
+}
+
+// Variances returns the variances of the errors of the forecasts 1 to steps ahead, growing with the differences
+// through the psi weights of the model written as an infinite moving average
+func (m *Model) Variances(steps int) []float64 {
+	a, b := m.polynomials()
+	// the autoregressive polynomial times the differences, back in the 1 - sum a[k] B^k form
+	ar := make([]float64, len(a))
+	ar[0] = 1
+	for k := 1; k < len(a); k++ {
+		ar[k] = -a[k]
+	}
+	for _, lag := range m.Order.lags() {
+		ar = multiply(ar, lagPolynomial([]float64{1}, lag, -1))
+	}
+	psi := make([]float64, steps)
+	res := make([]float64, steps)
+	sum := 0.0
+	for j := range psi {
+		if j == 0 {
+			psi[j] = 1
+		} else {
+			if j < len(b) {
+				psi[j] = b[j]
+			}
+			for k := 1; k < len(ar) && k <= j; k++ {
+				psi[j] -= ar[k] * psi[j-k]
+			}
+		}
+		sum += psi[j] * psi[j]
+		res[j] = m.Sigma2 * sum
+	}
+	return res
+}
+
+// Search fits the orders p <= maxP and q <= maxQ, the other orders as in base, and keeps the fit of the least AIC
+func Search(series []float64, base Order, maxP, maxQ int) (*Model, error) {
+	start := maxP + base.SP*base.S
+	var best *Model
+	var lastErr error
+	for p := 0; p <= maxP; p++ {
+		for q := 0; q <= maxQ; q++ {
+			order := base
+			order.P, order.Q = p, q
+			m, err := fit(series, order, start)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			if best == nil || m.AIC() < best.AIC() {
+				best = m
+			}
+		}
+	}
+	if best == nil {
+		return nil, lastErr
+	}
+	return best, nil
+}
+
+// stable tells whether the roots of 1 + sign*sum c[i] x^(i+1) lie outside the unit circle,
+// by stepping down the coefficients to the reflection ones, which must all be less than 1 in magnitude
+func stable(c []float64, sign float64) bool {
+	a := make([]float64, len(c))
+	for i, v := range c {
+		a[i] = -sign * v
+	}
+	for m := len(a); m > 0; m-- {
+		k := a[m-1]
+		if math.Abs(k) >= 1 {
+			return false
+		}
+		next := make([]float64, m-1)
+		for i := range next {
+			next[i] = (a[i] + k*a[m-2-i]) / (1 - k*k)
+		}
+		a = next
+	}
+	return true
+}
+
+// lagPolynomial returns 1 + sign*sum c[i] B^((i+1)*lag) as its coefficients
+func lagPolynomial(c []float64, lag int, sign float64) []float64 {
+	res := make([]float64, len(c)*lag+1)
+	res[0] = 1
+	for i, v := range c {
+		res[(i+1)*lag] = sign * v
+	}
+	return res
+}
+
+func multiply(x, y []float64) []float64 {
+	res := make([]float64, len(x)+len(y)-1)
+	for i, a := range x {
+		for j, b := range y {
+			res[i+j] += a * b
+		}
+	}
+	return res
+}
+
+func difference(series []float64, lag int) []float64 {
+	if len(series) <= lag {
+		return nil
+	}
+	res := make([]float64, len(series)-lag)
+	for i := range res {
+		res[i] = series[i+lag] - series[i]
+	}
+	return res
+}
+
+func differenced(series []float64, lags []int) []float64 {
+	for _, lag := range lags {
+		series = difference(series, lag)
+	}
+	return series
+}
+
+func mean(series []float64) float64 {
+	sum := 0.0
+	for _, v := range series {
+		sum += v
+	}
+	return sum / float64(len(series))
+}
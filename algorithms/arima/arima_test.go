@@ -0,0 +1,252 @@
+package arima
+
+import (
+	"context"
+	"fmt"
+	"github.com/LL-res/AOM/fake"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// simulate generates n points of an ARMA(1,1) process around mu with unit noise
+func simulate(n int, phi, theta, mu float64, seed int64) []float64 {
+	r := rand.New(rand.NewSource(seed))
+	res := make([]float64, n)
+	prevZ, prevE := 0.0, 0.0
+	for i := range res {
+		e := r.NormFloat64()
+		z := phi*prevZ + e + theta*prevE
+		res[i] = z + mu
+		prevZ, prevE = z, e
+	}
+	return res
+}
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		series    []float64
+		order     Order
+		wantAR    []float64
+		wantMA    []float64
+		wantSigma float64
+	}{
+		{
+			series:    simulate(2000, 0.7, 0, 10, 1),
+			order:     Order{P: 1},
+			wantAR:    []float64{0.7},
+			wantSigma: 1,
+		},
+		{
+			series:    simulate(2000, 0, 0.5, 10, 2),
+			order:     Order{Q: 1},
+			wantMA:    []float64{0.5},
+			wantSigma: 1,
+		},
+		{
+			series:    simulate(2000, 0.6, 0.4, 0, 3),
+			order:     Order{P: 1, Q: 1},
+			wantAR:    []float64{0.6},
+			wantMA:    []float64{0.4},
+			wantSigma: 1,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			m, err := Fit(tt.series, tt.order)
+			if err != nil {
+				t.Fatal(err)
+			}
+			check := func(name string, got, want []float64) {
+				if len(got) != len(want) {
+					t.Fatalf("got %s %v, want %v", name, got, want)
+				}
+				for j := range want {
+					if math.Abs(got[j]-want[j]) > 0.1 {
+						t.Errorf("got %s %v, want %v", name, got, want)
+					}
+				}
+			}
+			check("ar", m.AR, tt.wantAR)
+			check("ma", m.MA, tt.wantMA)
+			if math.Abs(m.Sigma2-tt.wantSigma) > 0.15 {
+				t.Errorf("got sigma2 %v, want %v", m.Sigma2, tt.wantSigma)
+			}
+		})
+	}
+}
+
+func TestForecast(t *testing.T) {
+	tests := []struct {
+		series []float64
+		order  Order
+		want   []float64
+	}{
+		{
+			// a line keeps its slope through the drift of the differences
+			series: line(50, func(i int) float64 { return 3 + 2*float64(i) }),
+			order:  Order{D: 1},
+			want:   []float64{103, 105, 107},
+		},
+		{
+			// a season of 4 repeats after the seasonal difference
+			series: line(40, func(i int) float64 { return []float64{1, 5, 2, 8}[i%4] }),
+			order:  Order{SD: 1, S: 4},
+			want:   []float64{1, 5, 2, 8, 1},
+		},
+		{
+			// the season rides on a trend
+			series: line(48, func(i int) float64 { return float64(i) + []float64{0, 10, 0, -10}[i%4] }),
+			order:  Order{D: 1, SD: 1, S: 4},
+			want:   []float64{48, 59, 50, 41},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			m, err := Fit(tt.series, tt.order)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := m.Forecast(tt.series, len(tt.want))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for j := range tt.want {
+				if math.Abs(got[j]-tt.want[j]) > 1e-6 {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestForecastAR(t *testing.T) {
+	m := &Model{Order: Order{P: 1}, AR: []float64{0.5}, Mean: 10}
+	got, err := m.Forecast([]float64{10, 14}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the deviation from the mean halves each step
+	want := []float64{12, 11, 10.5}
+	for j := range want {
+		if math.Abs(got[j]-want[j]) > 1e-9 {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestVariances(t *testing.T) {
+	tests := []struct {
+		model *Model
+		want  []float64
+	}{
+		{
+			// a random walk adds the variance of a step each step
+			model: &Model{Order: Order{D: 1}, Sigma2: 2},
+			want:  []float64{2, 4, 6},
+		},
+		{
+			// psi weights 1, 0.5, 0.25 of an AR(1)
+			model: &Model{Order: Order{P: 1}, AR: []float64{0.5}, Sigma2: 1},
+			want:  []float64{1, 1.25, 1.3125},
+		},
+		{
+			// psi weights 1, 0.4 of an MA(1)
+			model: &Model{Order: Order{Q: 1}, MA: []float64{0.4}, Sigma2: 1},
+			want:  []float64{1, 1.16, 1.16},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			got := tt.model.Variances(len(tt.want))
+			for j := range tt.want {
+				if math.Abs(got[j]-tt.want[j]) > 1e-9 {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	series := simulate(1500, 0.8, 0, 5, 4)
+	m, err := Search(series, Order{}, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// an AR(1) explains the series, the larger orders gain too little to pay for their terms
+	if m.Order.P == 0 || m.Order.P+m.Order.Q > 2 {
+		t.Errorf("got %s", m.Order)
+	}
+}
+
+func TestStable(t *testing.T) {
+	tests := []struct {
+		c    []float64
+		sign float64
+		want bool
+	}{
+		{c: nil, sign: 1, want: true},
+		{c: []float64{0.9}, sign: -1, want: true},
+		{c: []float64{1.1}, sign: -1, want: false},
+		{c: []float64{-1.1}, sign: 1, want: false},
+		// 1 - 1.5x + 0.56x^2 = (1-0.7x)(1-0.8x)
+		{c: []float64{1.5, -0.56}, sign: -1, want: true},
+		// 1 - 1.5x + 0.5x^2 = (1-x)(1-0.5x), a unit root
+		{c: []float64{1.5, -0.5}, sign: -1, want: false},
+		// 1 + 1.5x + 0.56x^2 = (1+0.7x)(1+0.8x)
+		{c: []float64{1.5, 0.56}, sign: 1, want: true},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			if got := stable(tt.c, tt.sign); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArima(t *testing.T) {
+	series := simulate(300, 0.7, 0, 50, 5)
+	worker := &fake.CollectorWorker{
+		N:        len(series),
+		Function: func(i int) float64 { return series[i] },
+		Start:    time.Now().Add(-time.Hour),
+		Interval: time.Second,
+	}
+	p, err := New(worker, map[string]string{"look_forward": "10", "look_backward": "200", "p": "1", "d": "0", "q": "0"}, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// predicting fits the model when it has not been trained
+	res, err := p.Predict(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.PredictMetric) != 10 || res.StartMetric != series[len(series)-1] || res.Loss <= 0 {
+		t.Fatalf("got %+v", res)
+	}
+	for i := range res.PredictMetric {
+		if !(res.Lower[i] < res.PredictMetric[i] && res.PredictMetric[i] < res.Upper[i]) || res.Confidence != 0.95 {
+			t.Fatalf("got %+v", res)
+		}
+	}
+	// the forecast decays towards the mean
+	if math.Abs(res.PredictMetric[9]-50) > math.Abs(res.StartMetric-50)+0.5 {
+		t.Errorf("got %v, start %v", res.PredictMetric, res.StartMetric)
+	}
+
+	_, err = New(worker, map[string]string{"look_forward": "10", "look_backward": "200", "seasonal_d": "1"}, "key")
+	if err == nil {
+		t.Error("seasonal terms without slen must be rejected")
+	}
+}
+
+func line(n int, f func(i int) float64) []float64 {
+	res := make([]float64, n)
+	for i := range res {
+		res[i] = f(i)
+	}
+	return res
+}
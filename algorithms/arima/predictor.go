@@ -0,0 +1,183 @@
+package arima
+
+import (
+	"context"
+	"fmt"
+	"github.com/LL-res/AOM/collector"
+	"github.com/LL-res/AOM/common/consts"
+	"github.com/LL-res/AOM/log"
+	ptype "github.com/LL-res/AOM/predictor/type"
+	"math"
+	"sync"
+	"time"
+)
+
+// Schema of the attributes in spec
+var Schema = ptype.Schema{
+	{Name: "look_forward", Description: "how many points to predict", Type: ptype.Int, Required: true, Min: ptype.Bound(1)},
+	{Name: "look_backward", Description: "how many points the model is fitted on and the prediction is based on", Type: ptype.Int, Required: true, Min: ptype.Bound(10)},
+	{Name: "p", Description: "the order of the autoregressive terms", Type: ptype.Int, Default: "1", Min: ptype.Bound(0), Max: ptype.Bound(10)},
+	{Name: "d", Description: "the order of the differences", Type: ptype.Int, Default: "1", Min: ptype.Bound(0), Max: ptype.Bound(2)},
+	{Name: "q", Description: "the order of the moving average terms", Type: ptype.Int, Default: "1", Min: ptype.Bound(0), Max: ptype.Bound(10)},
+	{Name: "seasonal_p", Description: "the order of the seasonal autoregressive terms", Type: ptype.Int, Default: "0", Min: ptype.Bound(0), Max: ptype.Bound(2)},
+	{Name: "seasonal_d", Description: "the order of the seasonal differences", Type: ptype.Int, Default: "0", Min: ptype.Bound(0), Max: ptype.Bound(1)},
+	{Name: "seasonal_q", Description: "the order of the seasonal moving average terms", Type: ptype.Int, Default: "0", Min: ptype.Bound(0), Max: ptype.Bound(2)},
+	{Name: "slen", Description: "the length of a season in points, needed by the seasonal terms", Type: ptype.Int, Default: "0", Min: ptype.Bound(0)},
+	{Name: "auto", Description: "search p and q up to max_p and max_q for the least AIC, in place of p and q", Type: ptype.Bool, Default: "false"},
+	{Name: "max_p", Description: "the largest p searched", Type: ptype.Int, Default: "3", Min: ptype.Bound(0), Max: ptype.Bound(10)},
+	{Name: "max_q", Description: "the largest q searched", Type: ptype.Int, Default: "3", Min: ptype.Bound(0), Max: ptype.Bound(10)},
+	ptype.Confidence,
+	{Name: "debug", Description: "log the fitted coefficients and the predictions", Type: ptype.Bool, Default: "false"},
+	{Name: "resolution", Description: "predict on the rollups of the history, raw points by default", Type: ptype.Duration, Enum: []string{"1m", "10m"}},
+}
+
+func init() {
+	ptype.Register(consts.ARIMA, ptype.Model{
+		Schema: Schema,
+		Factory: func(worker collector.MetricCollector, values ptype.Values, withModelKey string) (ptype.Predictor, error) {
+			return newArima(worker, values, withModelKey)
+		},
+	})
+}
+
+type Arima struct {
+	debug           bool
+	order           Order
+	auto            bool
+	maxP            int
+	maxQ            int
+	lookForward     int
+	lookBackward    int
+	confidence      float64
+	resolution      time.Duration
+	withModelKey    string
+	collectorWorker collector.MetricCollector
+
+	mu sync.Mutex
+	// the fitted model, nil until trained
+	model *Model
+}
+
+func New(collectorWorker collector.MetricCollector, model map[string]string, withModelKey string) (*Arima, error) {
+	values, err := Schema.Parse(model)
+	if err != nil {
+		return nil, err
+	}
+	return newArima(collectorWorker, values, withModelKey)
+}
+
+func newArima(collectorWorker collector.MetricCollector, values ptype.Values, withModelKey string) (*Arima, error) {
+	order := Order{
+		P:  values.Int("p"),
+		D:  values.Int("d"),
+		Q:  values.Int("q"),
+		SP: values.Int("seasonal_p"),
+		SD: values.Int("seasonal_d"),
+		SQ: values.Int("seasonal_q"),
+		S:  values.Int("slen"),
+	}
+	if order.SP+order.SD+order.SQ == 0 {
+		order.S = 0
+	}
+	if err := order.validate(); err != nil {
+		return nil, fmt.Errorf("attr[slen]: %w", err)
+	}
+	return &Arima{
+		debug:           values.Bool("debug"),
+		order:           order,
+		auto:            values.Bool("auto"),
+		maxP:            values.Int("max_p"),
+		maxQ:            values.Int("max_q"),
+		lookForward:     values.Int("look_forward"),
+		lookBackward:    values.Int("look_backward"),
+		confidence:      values.Float("confidence"),
+		resolution:      values.Duration("resolution"),
+		withModelKey:    withModelKey,
+		collectorWorker: collectorWorker,
+	}, nil
+}
+
+// Train fits the coefficients on the latest look_backward points of the history
+func (p *Arima) Train(ctx context.Context) error {
+	series, err := p.series()
+	if err != nil {
+		return err
+	}
+	var model *Model
+	if p.auto {
+		model, err = Search(series, p.order, p.maxP, p.maxQ)
+	} else {
+		model, err = Fit(series, p.order)
+	}
+	if err != nil {
+		return err
+	}
+	if p.debug {
+		log.Logger.Info("arima fitted", "order", model.Order.String(), "ar", model.AR, "ma", model.MA,
+			"sar", model.SAR, "sma", model.SMA, "sigma2", model.Sigma2, "aic", model.AIC())
+	}
+	p.mu.Lock()
+	p.model = model
+	p.mu.Unlock()
+	return nil
+}
+
+// Predict forecasts look_forward points after the latest ones, the model is fitted first when it has not been trained
+func (p *Arima) Predict(ctx context.Context) (ptype.PredictResult, error) {
+	p.mu.Lock()
+	model := p.model
+	p.mu.Unlock()
+	if model == nil {
+		if err := p.Train(ctx); err != nil {
+			return ptype.PredictResult{}, err
+		}
+		p.mu.Lock()
+		model = p.model
+		p.mu.Unlock()
+	}
+	series, err := p.series()
+	if err != nil {
+		return ptype.PredictResult{}, err
+	}
+	predMetrics, err := model.Forecast(series, p.lookForward)
+	if err != nil {
+		return ptype.PredictResult{}, err
+	}
+	if p.debug {
+		log.Logger.Info("predict metrics", "metrics", predMetrics)
+	}
+	z := ptype.Z(p.confidence)
+	lower, upper := make([]float64, len(predMetrics)), make([]float64, len(predMetrics))
+	for i, v := range model.Variances(len(predMetrics)) {
+		half := z * math.Sqrt(v)
+		lower[i], upper[i] = predMetrics[i]-half, predMetrics[i]+half
+	}
+	return ptype.PredictResult{
+		StartMetric:   series[len(series)-1],
+		Loss:          model.Sigma2,
+		PredictMetric: predMetrics,
+		Lower:         lower,
+		Upper:         upper,
+		Confidence:    p.confidence,
+	}, nil
+}
+
+func (p *Arima) series() ([]float64, error) {
+	metrics, err := p.collectorWorker.Window(p.resolution, p.lookBackward)
+	if err != nil {
+		return nil, err
+	}
+	series := make([]float64, 0, len(metrics))
+	for _, m := range metrics {
+		series = append(series, m.Value)
+	}
+	return series, nil
+}
+
+func (p *Arima) GetType() string {
+	return consts.ARIMA
+}
+
+func (p *Arima) Key() string {
+	return p.withModelKey
+}
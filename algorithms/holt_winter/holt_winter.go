@@ -2,14 +2,17 @@ package holt_winter
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
+	"github.com/LL-res/AOM/algorithms/optimize"
 	"github.com/LL-res/AOM/collector"
 	"github.com/LL-res/AOM/common/consts"
-	"github.com/LL-res/AOM/common/errs"
 	"github.com/LL-res/AOM/log"
 	ptype "github.com/LL-res/AOM/predictor/type"
 	"github.com/LL-res/AOM/utils"
+	"math"
 	"strconv"
+	"sync"
+	"time"
 )
 
 type HoltWinter struct {
@@ -20,25 +23,64 @@ type HoltWinter struct {
 	alpha           float64
 	beta            float64
 	gamma           float64
+	phi             float64
+	confidence      float64
+	multiplicative  bool
+	resolution      time.Duration
 	withModelKey    string
 	collectorWorker collector.MetricCollector
+
+	// mu guards the smoothing factors, which Train replaces
+	mu  sync.RWMutex
+	fit *Fit
 }
-type Param struct {
-	Slen         string `json:"slen,omitempty"`
-	LookForward  string `json:"look_forward,omitempty"`
-	LookBackward string `json:"look_backward,omitempty"`
-	Alpha        string `json:"alpha,omitempty"`
-	Beta         string `json:"beta,omitempty"`
-	Gamma        string `json:"gamma,omitempty"`
-	Debug        string `json:"debug,omitempty"`
+
+// Fit is the outcome of Train, the smoothing factors minimizing the one-step-ahead SSE over the lookback window
+type Fit struct {
+	Alpha float64
+	Beta  float64
+	Gamma float64
+	SSE   float64
+}
+
+// the seasonal modes
+const (
+	additive       = "additive"
+	multiplicative = "multiplicative"
+)
+
+// Schema of the attributes in spec
+var Schema = ptype.Schema{
+	{Name: "slen", Description: "the length of a season in points", Type: ptype.Int, Required: true, Min: ptype.Bound(1)},
+	{Name: "look_forward", Description: "how many points to predict", Type: ptype.Int, Required: true, Min: ptype.Bound(1)},
+	{Name: "look_backward", Description: "how many points the prediction is based on, at least two seasons", Type: ptype.Int, Required: true, Min: ptype.Bound(2)},
+	{Name: "alpha", Description: "the smoothing factor of the level, where the fit starts when trained", Type: ptype.Float, Default: "0.5", Min: ptype.Bound(0), Max: ptype.Bound(1)},
+	{Name: "beta", Description: "the smoothing factor of the trend, where the fit starts when trained", Type: ptype.Float, Default: "0.1", Min: ptype.Bound(0), Max: ptype.Bound(1)},
+	{Name: "gamma", Description: "the smoothing factor of the seasonality, where the fit starts when trained", Type: ptype.Float, Default: "0.5", Min: ptype.Bound(0), Max: ptype.Bound(1)},
+	{Name: "seasonal", Description: "whether the seasonality adds to the level or scales with it, multiplicative needs positive metrics", Type: ptype.String, Default: additive, Enum: []string{additive, multiplicative}},
+	{Name: "phi", Description: "the damping factor of the trend, 1 keeps it undamped, not fitted by Train", Type: ptype.Float, Default: "1", Min: ptype.Bound(0), Max: ptype.Bound(1)},
+	ptype.Confidence,
+	{Name: "debug", Description: "log and plot the predictions", Type: ptype.Bool, Default: "false"},
+	{Name: "resolution", Description: "predict on the rollups of the history, raw points by default", Type: ptype.Duration, Enum: []string{"1m", "10m"}},
+}
+
+func init() {
+	ptype.Register(consts.HOLT_WINTER, ptype.Model{
+		Schema: Schema,
+		Factory: func(worker collector.MetricCollector, values ptype.Values, withModelKey string) (ptype.Predictor, error) {
+			return newHoltWinter(worker, values, withModelKey)
+		},
+	})
 }
 
 func (p *HoltWinter) Predict(ctx context.Context) (ptype.PredictResult, error) {
-	if p.collectorWorker.DataCap() < p.lookBackward {
-		return ptype.PredictResult{}, errs.NO_SUFFICENT_DATA
+	metrics, err := p.collectorWorker.Window(p.resolution, p.lookBackward)
+	if err != nil {
+		return ptype.PredictResult{}, err
 	}
-	metrics := p.collectorWorker.Send()
-	metrics = metrics[len(metrics)-p.lookBackward:]
+	p.mu.RLock()
+	alpha, beta, gamma := p.alpha, p.beta, p.gamma
+	p.mu.RUnlock()
 	if p.debug {
 		ms := make([]float64, 0)
 		ts := make([]string, 0)
@@ -52,17 +94,24 @@ func (p *HoltWinter) Predict(ctx context.Context) (ptype.PredictResult, error) {
 	for _, m := range metrics {
 		series = append(series, m.Value)
 	}
-	predMetrics := p.tripleExponentialSmoothing(series)
+	if err := p.checkSeries(series); err != nil {
+		return ptype.PredictResult{}, err
+	}
+	predMetrics, sse := p.tripleExponentialSmoothing(series, alpha, beta, gamma)
 	if p.debug {
 		log.Logger.Info("predict metrics", "metrics", predMetrics)
 		if err := utils.PlotLine(series, predMetrics, "holt_winter"); err != nil {
 			log.Logger.Error(err, "debug plot failed")
 		}
 	}
+	lower, upper := p.intervals(predMetrics, len(series), sse, alpha, beta, gamma)
 	res := ptype.PredictResult{
 		StartMetric:   series[len(series)-1],
-		Loss:          -1,
+		Loss:          sse / float64(len(series)-1),
 		PredictMetric: predMetrics,
+		Lower:         lower,
+		Upper:         upper,
+		Confidence:    p.confidence,
 	}
 	return res, nil
 }
@@ -71,14 +120,86 @@ func (p *HoltWinter) GetType() string {
 	return consts.HOLT_WINTER
 }
 
+// Train fits alpha, beta and gamma to the lookback window by minimizing the SSE of the one-step-ahead forecasts,
+// starting from the current factors
 func (p *HoltWinter) Train(ctx context.Context) error {
+	metrics, err := p.collectorWorker.Window(p.resolution, p.lookBackward)
+	if err != nil {
+		return err
+	}
+	series := make([]float64, 0, len(metrics))
+	for _, m := range metrics {
+		series = append(series, m.Value)
+	}
+	if err := p.checkSeries(series); err != nil {
+		return err
+	}
+	p.mu.RLock()
+	x0 := []float64{p.alpha, p.beta, p.gamma}
+	p.mu.RUnlock()
+	sse := func(x []float64) float64 {
+		for _, v := range x {
+			if v < 0 || v > 1 {
+				return math.Inf(1)
+			}
+		}
+		_, sse := p.tripleExponentialSmoothing(series, x[0], x[1], x[2])
+		return sse
+	}
+	res := optimize.NelderMead(sse, x0, optimize.Settings{})
+	if math.IsInf(res.F, 1) {
+		return fmt.Errorf("no smoothing factors fit the lookback window")
+	}
+	fit := &Fit{Alpha: res.X[0], Beta: res.X[1], Gamma: res.X[2], SSE: res.F}
+	log.Logger.Info("holt winter fitted", "key", p.withModelKey, "alpha", fit.Alpha, "beta", fit.Beta, "gamma", fit.Gamma, "sse", fit.SSE)
+	p.mu.Lock()
+	p.alpha, p.beta, p.gamma = fit.Alpha, fit.Beta, fit.Gamma
+	p.fit = fit
+	p.mu.Unlock()
 	return nil
 }
 
+// Fitted returns the outcome of the last Train, nil until trained
+func (p *HoltWinter) Fitted() *Fit {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.fit
+}
+
+// Report shows the smoothing factors fitted by the last Train and their SSE in status
+func (p *HoltWinter) Report() map[string]string {
+	fit := p.Fitted()
+	if fit == nil {
+		return nil
+	}
+	format := func(v float64) string {
+		return strconv.FormatFloat(v, 'g', 6, 64)
+	}
+	return map[string]string{
+		"alpha": format(fit.Alpha),
+		"beta":  format(fit.Beta),
+		"gamma": format(fit.Gamma),
+		"sse":   format(fit.SSE),
+	}
+}
+
 func (p *HoltWinter) Key() string {
 	return p.withModelKey
 }
 
+// checkSeries rejects the metrics the seasonal mode cannot smooth
+func (p *HoltWinter) checkSeries(series []float64) error {
+	if !p.multiplicative {
+		return nil
+	}
+	for _, v := range series {
+		if v <= 0 {
+			return fmt.Errorf("multiplicative seasonality needs positive metrics, got %v", v)
+		}
+	}
+	return nil
+}
+
 func (p *HoltWinter) initialTrend(series []float64) float64 {
 	sum := 0.0
 	for i := 0; i < p.slen; i++ {
@@ -105,7 +226,11 @@ func (p *HoltWinter) initialSeasonalComponents(series []float64) map[int]float64
 	for i := 0; i < p.slen; i++ {
 		sumOfValsOverAvg := 0.0
 		for j := 0; j < nSeasons; j++ {
-			sumOfValsOverAvg += series[p.slen*j+i] - seasonAverages[j]
+			if p.multiplicative {
+				sumOfValsOverAvg += series[p.slen*j+i] / seasonAverages[j]
+			} else {
+				sumOfValsOverAvg += series[p.slen*j+i] - seasonAverages[j]
+			}
 		}
 		seasonals[i] = sumOfValsOverAvg / float64(nSeasons)
 	}
@@ -113,11 +238,56 @@ func (p *HoltWinter) initialSeasonalComponents(series []float64) map[int]float64
 	return seasonals
 }
 
-func (p *HoltWinter) tripleExponentialSmoothing(series []float64) []float64 {
+// intervals bounds the smoothed series of n points and the forecasts after it, from the variance of the one-step errors,
+// which grows over the forecast steps the way it does for the additive model, an approximation in the multiplicative one
+func (p *HoltWinter) intervals(result []float64, n int, sse, alpha, beta, gamma float64) ([]float64, []float64) {
+	z := ptype.Z(p.confidence)
+	sigma2 := sse / float64(n-1)
+	lower, upper := make([]float64, len(result)), make([]float64, len(result))
+	// the variance of the h-step forecast is sigma2 * (1 + sum of c_j^2 for j < h)
+	factor, damped := 1.0, 0.0
+	for i, v := range result {
+		if h := i - n + 1; h > 1 {
+			j := h - 1
+			damped = damped*p.phi + p.phi
+			c := alpha * (1 + beta*damped)
+			if j%p.slen == 0 {
+				c += gamma
+			}
+			factor += c * c
+		}
+		half := z * math.Sqrt(sigma2*factor)
+		lower[i], upper[i] = math.Max(0, v-half), v+half
+	}
+	return lower, upper
+}
+
+// season applies the seasonal component s to the level
+func (p *HoltWinter) season(level, s float64) float64 {
+	if p.multiplicative {
+		return level * s
+	}
+	return level + s
+}
+
+// deseason removes the seasonal component s from val
+func (p *HoltWinter) deseason(val, s float64) float64 {
+	if p.multiplicative {
+		return val / s
+	}
+	return val - s
+}
+
+// tripleExponentialSmoothing returns the smoothed series followed by the forecasts, which are never negative,
+// and the SSE of the one-step-ahead forecasts of the series
+func (p *HoltWinter) tripleExponentialSmoothing(series []float64, alpha, beta, gamma float64) ([]float64, float64) {
 	result := make([]float64, 0)
+	sse := 0.0
 	seasonals := p.initialSeasonalComponents(series)
 	smooth := series[0]
 	trend := p.initialTrend(series)
+	// the sum of the damping factors over the steps forecasted so far
+	damped := 0.0
 
 	for i := 0; i < len(series)+p.lookForward; i++ {
 		if i == 0 {
@@ -125,68 +295,47 @@ func (p *HoltWinter) tripleExponentialSmoothing(series []float64) []float64 {
 			continue
 		}
 		if i >= len(series) {
-			m := i - len(series) + 1
-			result = append(result, (smooth+float64(m)*trend)+seasonals[i%p.slen])
+			damped = damped*p.phi + p.phi
+			result = append(result, math.Max(0, p.season(smooth+damped*trend, seasonals[i%p.slen])))
 		} else {
 			val := series[i]
 			lastSmooth := smooth
-			smooth = p.alpha*(val-seasonals[i%p.slen]) + (1-p.alpha)*(smooth+trend)
-			trend = p.beta*(smooth-lastSmooth) + (1-p.beta)*trend
-			seasonals[i%p.slen] = p.gamma*(val-smooth) + (1-p.gamma)*seasonals[i%p.slen]
-			result = append(result, smooth+trend+seasonals[i%p.slen])
+			sse += math.Pow(val-p.season(smooth+p.phi*trend, seasonals[i%p.slen]), 2)
+			smooth = alpha*p.deseason(val, seasonals[i%p.slen]) + (1-alpha)*(smooth+p.phi*trend)
+			trend = beta*(smooth-lastSmooth) + (1-beta)*p.phi*trend
+			seasonals[i%p.slen] = gamma*p.deseason(val, smooth) + (1-gamma)*seasonals[i%p.slen]
+			result = append(result, p.season(smooth+p.phi*trend, seasonals[i%p.slen]))
 		}
 	}
 
-	return result
+	return result, sse
 }
 func New(collectorWorker collector.MetricCollector, model map[string]string, withModelKey string) (*HoltWinter, error) {
-	j, err := json.Marshal(model)
+	values, err := Schema.Parse(model)
 	if err != nil {
 		return nil, err
 	}
-	param := Param{}
-	err = json.Unmarshal(j, &param)
-	if err != nil {
-		return nil, err
-	}
-	slen, err := strconv.Atoi(param.Slen)
-	if err != nil {
-		return nil, err
-	}
-	lookForward, err := strconv.Atoi(param.LookForward)
-	if err != nil {
-		return nil, err
-	}
-	lookBack, err := strconv.Atoi(param.LookBackward)
-	if err != nil {
-		return nil, err
-	}
-	alpha, err := strconv.ParseFloat(param.Alpha, 64)
-	if err != nil {
-		return nil, err
-	}
-	beta, err := strconv.ParseFloat(param.Beta, 64)
-	if err != nil {
-		return nil, err
-	}
-	gamma, err := strconv.ParseFloat(param.Gamma, 64)
-	if err != nil {
-		return nil, err
-	}
-	debug := false
-	if param.Debug == "true" {
-		debug = true
+	return newHoltWinter(collectorWorker, values, withModelKey)
+}
+
+func newHoltWinter(collectorWorker collector.MetricCollector, values ptype.Values, withModelKey string) (*HoltWinter, error) {
+	slen, lookBack := values.Int("slen"), values.Int("look_backward")
+	if lookBack < 2*slen {
+		return nil, fmt.Errorf("attr[look_backward] %d must cover two seasons of slen %d", lookBack, slen)
 	}
 	return &HoltWinter{
 		slen:            slen,
-		lookForward:     lookForward,
+		lookForward:     values.Int("look_forward"),
 		lookBackward:    lookBack,
-		alpha:           alpha,
-		beta:            beta,
-		gamma:           gamma,
+		alpha:           values.Float("alpha"),
+		beta:            values.Float("beta"),
+		gamma:           values.Float("gamma"),
+		phi:             values.Float("phi"),
+		confidence:      values.Float("confidence"),
+		multiplicative:  values.String("seasonal") == multiplicative,
+		resolution:      values.Duration("resolution"),
 		withModelKey:    withModelKey,
 		collectorWorker: collectorWorker,
-		debug:           debug,
+		debug:           values.Bool("debug"),
 	}, nil
-
 }
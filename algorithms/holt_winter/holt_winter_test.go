@@ -1,15 +1,160 @@
 package holt_winter
 
 import (
+	"context"
+	"github.com/LL-res/AOM/fake"
+	"github.com/LL-res/AOM/log"
 	"testing"
+	"time"
 )
 
-func TestHoltWinter(t *testing.T) {
-	//series := []float64{30, 21, 29, 31, 40, 48, 53, 47, 37, 39, 31, 29, 17, 9, 20, 24, 27, 35, 41, 38,
-	//	27, 31, 27, 26, 21, 13, 21, 18, 33, 35, 40, 36, 22, 24, 21, 20, 17, 14, 17, 19,
-	//	26, 29, 40, 31, 20, 24, 18, 26, 17, 9, 17, 21, 28, 32, 46, 33, 23, 28, 22, 27,
-	//	18, 8, 17, 21, 31, 34, 44, 38, 31, 30, 26, 32}
-	//fmt.Println(initialTrend(series, 12))
-	//fmt.Println(initialSeasonalComponents(series, 12))
-	//fmt.Println(tripleExponentialSmoothing(series, 12, 0.716, 0.029, 0.993, 24))
+var seasonalSeries = []float64{30, 21, 29, 31, 40, 48, 53, 47, 37, 39, 31, 29, 17, 9, 20, 24, 27, 35, 41, 38,
+	27, 31, 27, 26, 21, 13, 21, 18, 33, 35, 40, 36, 22, 24, 21, 20, 17, 14, 17, 19,
+	26, 29, 40, 31, 20, 24, 18, 26, 17, 9, 17, 21, 28, 32, 46, 33, 23, 28, 22, 27,
+	18, 8, 17, 21, 31, 34, 44, 38, 31, 30, 26, 32}
+
+func TestHoltWinterTrain(t *testing.T) {
+	log.Init()
+	worker := &fake.CollectorWorker{
+		N:        len(seasonalSeries),
+		Function: func(i int) float64 { return seasonalSeries[i] },
+		Start:    time.Now().Add(-time.Hour),
+		Interval: time.Second,
+	}
+	p, err := New(worker, map[string]string{"slen": "12", "look_forward": "24", "look_backward": "72"}, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Fitted() != nil || p.Report() != nil {
+		t.Fatal("fitted before training")
+	}
+	before, err := p.Predict(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Train(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	fit := p.Fitted()
+	for _, v := range []float64{fit.Alpha, fit.Beta, fit.Gamma} {
+		if v < 0 || v > 1 {
+			t.Fatalf("got %+v, want the factors in [0,1]", fit)
+		}
+	}
+	if report := p.Report(); len(report) != 4 || report["sse"] == "" {
+		t.Errorf("got report %v, want the fitted factors and sse", report)
+	}
+	after, err := p.Predict(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the defaults are where the fit starts, it can only improve on them
+	if after.Loss >= before.Loss {
+		t.Errorf("got loss %v after training, %v before", after.Loss, before.Loss)
+	}
+	// the intervals widen over the forecasts
+	n := len(seasonalSeries)
+	if width := func(i int) float64 { return after.Upper[i] - after.PredictMetric[i] }; !(width(n) < width(n+12) && width(n-1) == width(0)) {
+		t.Errorf("got lower %v, upper %v", after.Lower, after.Upper)
+	}
+	if after.Loss*float64(len(seasonalSeries)-1)-fit.SSE > 1e-9 {
+		t.Errorf("got loss %v, want the SSE %v over the points", after.Loss, fit.SSE)
+	}
+}
+
+func TestHoltWinterForecast(t *testing.T) {
+	tests := []struct {
+		name  string
+		attr  map[string]string
+		value func(i int) float64
+		check func(t *testing.T, forecast []float64)
+	}{
+		{
+			name:  "a damped trend levels off",
+			attr:  map[string]string{"phi": "0.8", "alpha": "0.5", "beta": "0.5", "gamma": "0"},
+			value: func(i int) float64 { return 10 * float64(i) },
+			check: func(t *testing.T, forecast []float64) {
+				// the steps shrink by phi, adding up to at most 10*0.8/0.2 over the last point
+				if last := forecast[len(forecast)-1]; last > 230+40 || last < forecast[0] {
+					t.Errorf("got %v", forecast)
+				}
+			},
+		},
+		{
+			name:  "a falling trend stops at 0",
+			attr:  map[string]string{},
+			value: func(i int) float64 { return 240 - 10*float64(i) },
+			check: func(t *testing.T, forecast []float64) {
+				if last := forecast[len(forecast)-1]; last != 0 {
+					t.Errorf("got %v, want the forecasts clamped at 0", forecast)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			worker := &fake.CollectorWorker{
+				N:        24,
+				Function: tt.value,
+				Start:    time.Now().Add(-time.Hour),
+				Interval: time.Second,
+			}
+			attr := map[string]string{"slen": "4", "look_forward": "40", "look_backward": "24"}
+			for k, v := range tt.attr {
+				attr[k] = v
+			}
+			p, err := New(worker, attr, "key")
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := p.Predict(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the smoothed window comes first
+			tt.check(t, res.PredictMetric[24:])
+		})
+	}
+}
+
+func TestHoltWinterMultiplicativeNonPositive(t *testing.T) {
+	worker := &fake.CollectorWorker{
+		N:        24,
+		Function: func(i int) float64 { return float64(i % 4) },
+		Start:    time.Now().Add(-time.Hour),
+		Interval: time.Second,
+	}
+	p, err := New(worker, map[string]string{"slen": "4", "look_forward": "4", "look_backward": "24", "seasonal": "multiplicative"}, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Predict(context.Background()); err == nil {
+		t.Error("expected an error on a zero metric")
+	}
+}
+
+func TestHoltWinterMultiplicative(t *testing.T) {
+	pattern := []float64{0.5, 1, 1.5, 1}
+	worker := &fake.CollectorWorker{
+		N:        24,
+		Function: func(i int) float64 { return (100 + 10*float64(i)) * pattern[i%4] },
+		Start:    time.Now().Add(-time.Hour),
+		Interval: time.Second,
+	}
+	loss := make(map[string]float64)
+	for _, mode := range []string{"additive", "multiplicative"} {
+		p, err := New(worker, map[string]string{"slen": "4", "look_forward": "4", "look_backward": "24", "seasonal": mode}, "key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := p.Predict(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		loss[mode] = res.Loss
+	}
+	// the amplitude of the season grows with the level
+	if loss["multiplicative"] >= loss["additive"] {
+		t.Errorf("got loss %v", loss)
+	}
 }
@@ -0,0 +1,132 @@
+package optimize
+
+import (
+	"math"
+	"sort"
+)
+
+// Settings of NelderMead, the zero values are replaced by the defaults
+type Settings struct {
+	// MaxIter bounds the iterations, default 200 per dimension
+	MaxIter int
+	// Tol stops the search once the values at the vertices of the simplex differ by less, default 1e-8
+	Tol float64
+	// Step is the size of the initial simplex around x0, default 0.1, or 5% of the coordinate when it is not 0
+	Step float64
+}
+
+// Result of a minimization
+type Result struct {
+	X     []float64
+	F     float64
+	Iter  int
+	Evals int
+}
+
+// NelderMead minimizes f starting at x0 with the downhill simplex method, f needs no gradient
+// and may return +Inf to keep the search out of a region
+func NelderMead(f func(x []float64) float64, x0 []float64, settings Settings) Result {
+	n := len(x0)
+	if settings.MaxIter <= 0 {
+		settings.MaxIter = 200 * max(n, 1)
+	}
+	if settings.Tol <= 0 {
+		settings.Tol = 1e-8
+	}
+	res := Result{}
+	eval := func(x []float64) float64 {
+		res.Evals++
+		v := f(x)
+		if math.IsNaN(v) {
+			return math.Inf(1)
+		}
+		return v
+	}
+	if n == 0 {
+		res.X, res.F = []float64{}, eval(x0)
+		return res
+	}
+
+	type vertex struct {
+		x []float64
+		f float64
+	}
+	simplex := make([]vertex, n+1)
+	simplex[0] = vertex{x: append([]float64(nil), x0...)}
+	for i := 0; i < n; i++ {
+		x := append([]float64(nil), x0...)
+		step := settings.Step
+		if step == 0 {
+			step = 0.1
+			if x[i] != 0 {
+				step = 0.05 * x[i]
+			}
+		}
+		x[i] += step
+		simplex[i+1] = vertex{x: x}
+	}
+	for i := range simplex {
+		simplex[i].f = eval(simplex[i].x)
+	}
+
+	// the standard coefficients of reflection, expansion, contraction and shrink
+	const alpha, gamma, rho, sigma = 1.0, 2.0, 0.5, 0.5
+	point := func(centroid, x []float64, coef float64) []float64 {
+		res := make([]float64, n)
+		for i := range res {
+			res[i] = centroid[i] + coef*(x[i]-centroid[i])
+		}
+		return res
+	}
+	for res.Iter = 0; res.Iter < settings.MaxIter; res.Iter++ {
+		sort.SliceStable(simplex, func(i, j int) bool { return simplex[i].f < simplex[j].f })
+		best, worst := simplex[0], simplex[n]
+		if math.Abs(worst.f-best.f) <= settings.Tol*(math.Abs(best.f)+settings.Tol) && !math.IsInf(worst.f, 1) {
+			break
+		}
+		centroid := make([]float64, n)
+		for _, v := range simplex[:n] {
+			for i := range centroid {
+				centroid[i] += v.x[i] / float64(n)
+			}
+		}
+		reflected := point(centroid, worst.x, -alpha)
+		fr := eval(reflected)
+		switch {
+		case fr < best.f:
+			expanded := point(centroid, worst.x, -gamma)
+			if fe := eval(expanded); fe < fr {
+				simplex[n] = vertex{x: expanded, f: fe}
+			} else {
+				simplex[n] = vertex{x: reflected, f: fr}
+			}
+		case fr < simplex[n-1].f:
+			simplex[n] = vertex{x: reflected, f: fr}
+		default:
+			// contract towards the better of the worst and the reflected point
+			towards := worst
+			if fr < worst.f {
+				towards = vertex{x: reflected, f: fr}
+			}
+			contracted := point(centroid, towards.x, rho)
+			if fc := eval(contracted); fc < towards.f {
+				simplex[n] = vertex{x: contracted, f: fc}
+				continue
+			}
+			for i := 1; i <= n; i++ {
+				simplex[i].x = point(best.x, simplex[i].x, sigma)
+				simplex[i].f = eval(simplex[i].x)
+			}
+		}
+	}
+	sort.SliceStable(simplex, func(i, j int) bool { return simplex[i].f < simplex[j].f })
+	res.X, res.F = simplex[0].x, simplex[0].f
+	return res
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,53 @@
+package optimize
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestNelderMead(t *testing.T) {
+	tests := []struct {
+		f    func(x []float64) float64
+		x0   []float64
+		want []float64
+	}{
+		{
+			// a shifted bowl
+			f: func(x []float64) float64 {
+				return (x[0]-3)*(x[0]-3) + 2*(x[1]+1)*(x[1]+1)
+			},
+			x0:   []float64{0, 0},
+			want: []float64{3, -1},
+		},
+		{
+			// rosenbrock, a curved valley
+			f: func(x []float64) float64 {
+				return 100*math.Pow(x[1]-x[0]*x[0], 2) + math.Pow(1-x[0], 2)
+			},
+			x0:   []float64{-1.2, 1},
+			want: []float64{1, 1},
+		},
+		{
+			// the minimum of the parabola lies outside the feasible region x <= 0.5
+			f: func(x []float64) float64 {
+				if x[0] > 0.5 {
+					return math.Inf(1)
+				}
+				return (x[0] - 1) * (x[0] - 1)
+			},
+			x0:   []float64{0},
+			want: []float64{0.5},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			res := NelderMead(tt.f, tt.x0, Settings{MaxIter: 5000, Tol: 1e-12})
+			for j := range tt.want {
+				if math.Abs(res.X[j]-tt.want[j]) > 1e-3 {
+					t.Errorf("got %v after %d iterations, want %v", res.X, res.Iter, tt.want)
+				}
+			}
+		})
+	}
+}
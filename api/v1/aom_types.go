@@ -19,6 +19,7 @@ package v1
 import (
 	"github.com/LL-res/AOM/common/basetype"
 	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -45,9 +46,169 @@ type AOMSpec struct {
 	Interval int `json:"interval"`
 }
 type Collector struct {
+	// Type decides where the metrics come from, prometheus by default.
+	// metrics-api reads the cpu and memory usage of the scale target's pods from metrics.k8s.io,
+	// the query of a metric is then one of cpu, memory or avg|sum|max|min(cpu|memory).
+	// file replays recorded traces, the query of a metric is then the path of a csv or jsonl trace.
+	// remote-write receives the series pushed to the remote-write endpoint of the operator,
+	// the query of a metric is then a series selector, e.g. http_requests_total{job="api"},
+	// the latest samples of the matching series are summed every scrape interval.
+	// http polls the json endpoint at address, the query of a metric is then a jsonpath extracting a number,
+	// optionally led by a path resolved against address, e.g. /api/stats {.orders.inFlight}.
+	// pods scrapes the metrics endpoint of the scale target's pods, the query of a metric is then a series selector
+	// optionally aggregating the pods with avg, sum, max or min, e.g. avg(http_requests_total{code="200"}),
+	// counters are turned into per second rates.
+	// sql runs the query of a metric against the database of spec.collector.sql, it returns either a single
+	// numeric value or time and value rows, e.g. SELECT count(*) FROM orders WHERE created_at > now() - interval '1 minute'.
+	// exec runs the query of a metric with /bin/sh -c in the operator's container, its stdout is either a single number
+	// or a json array of time and value samples, e.g. [{"time": 1700000000, "value": 3}],
+	// it is only allowed once the operator is started with --enable-exec-collector
+	// +kubebuilder:validation:Enum=prometheus;metrics-api;file;remote-write;http;pods;sql;exec
+	// +optional
+	Type    string `json:"type,omitempty"`
 	Address string `json:"address"`
 	// LookForward * ScrapInterval = the time to look forward
 	ScrapeInterval int `json:"scrapeInterval"`
+	// Auth configures how the collector authenticates against the server
+	// +optional
+	Auth *CollectorAuth `json:"auth,omitempty"`
+	// Headers are added to every request, e.g. X-Scope-OrgID for a multi-tenant server
+	// +optional
+	Headers map[string]string `json:"headers,omitempty"`
+	// File configures the replay of the file collector
+	// +optional
+	File *FileCollector `json:"file,omitempty"`
+	// HTTP configures the requests of the http collector, auth and headers apply to them as well
+	// +optional
+	HTTP *HTTPCollector `json:"http,omitempty"`
+	// Pods configures where the pods collector scrapes the pods
+	// +optional
+	Pods *PodsCollector `json:"pods,omitempty"`
+	// SQL configures the database the sql collector queries
+	// +optional
+	SQL *SQLCollector `json:"sql,omitempty"`
+	// Exec configures how the exec collector runs the commands
+	// +optional
+	Exec *ExecCollector `json:"exec,omitempty"`
+	// StaleThreshold in seconds, a metric whose latest sample is older than it is stale,
+	// default 10 scrape intervals
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	StaleThreshold int `json:"staleThreshold,omitempty"`
+	// StalePolicy decides how scaling treats stale metrics, Block holds the current replicas while
+	// any metric is stale, Ignore leaves the stale metrics out of the decision. Block by default
+	// +kubebuilder:validation:Enum=Block;Ignore
+	// +optional
+	StalePolicy string `json:"stalePolicy,omitempty"`
+}
+
+type ExecCollector struct {
+	// Timeout of a command in seconds, it is killed afterwards, default 10
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Timeout int `json:"timeout,omitempty"`
+	// MaxConcurrency caps the commands running at once, default 4
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxConcurrency int `json:"maxConcurrency,omitempty"`
+}
+
+type FileCollector struct {
+	// Speed is how many times faster than wall clock the traces are replayed, default 1
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Speed int `json:"speed,omitempty"`
+	// Loop starts the traces over once they are exhausted
+	// +optional
+	Loop bool `json:"loop,omitempty"`
+}
+
+type HTTPCollector struct {
+	// Method is GET by default
+	// +kubebuilder:validation:Enum=GET;POST
+	// +optional
+	Method string `json:"method,omitempty"`
+	// Body is sent as application/json
+	// +optional
+	Body string `json:"body,omitempty"`
+	// Timeout of a request in seconds, default 10
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Timeout int `json:"timeout,omitempty"`
+}
+
+type PodsCollector struct {
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int `json:"port"`
+	// Path is /metrics by default
+	// +optional
+	Path string `json:"path,omitempty"`
+	// Scheme is http by default
+	// +kubebuilder:validation:Enum=http;https
+	// +optional
+	Scheme string `json:"scheme,omitempty"`
+}
+
+type SQLCollector struct {
+	// Driver is the database/sql driver, postgres or mysql
+	// +kubebuilder:validation:Enum=postgres;mysql
+	Driver string `json:"driver"`
+	// DSN references the data source name in a Secret of the AOM namespace, since it usually holds the password
+	DSN corev1.SecretKeySelector `json:"dsn"`
+	// Timeout of a query in seconds, default 10
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Timeout int `json:"timeout,omitempty"`
+	// RefreshInterval in seconds decides how often the DSN secret is read again,
+	// so that a rotated password is picked up without recreating the AOM, default 60
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	RefreshInterval int `json:"refreshInterval,omitempty"`
+}
+
+// CollectorAuth references the credentials kept in Secrets or ConfigMaps of the AOM namespace
+type CollectorAuth struct {
+	// +optional
+	BearerToken *corev1.SecretKeySelector `json:"bearerToken,omitempty"`
+	// +optional
+	BasicAuth *BasicAuth `json:"basicAuth,omitempty"`
+	// +optional
+	TLS *TLSConfig `json:"tls,omitempty"`
+	// RefreshInterval in seconds decides how often the referenced credentials are read again,
+	// so that rotated secrets are picked up without recreating the AOM, default 60
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	RefreshInterval int `json:"refreshInterval,omitempty"`
+}
+
+type BasicAuth struct {
+	Username corev1.SecretKeySelector `json:"username"`
+	Password corev1.SecretKeySelector `json:"password"`
+}
+
+type TLSConfig struct {
+	// CA is the bundle used to verify the server certificate
+	// +optional
+	CA *SecretOrConfigMap `json:"ca,omitempty"`
+	// Cert is the client certificate
+	// +optional
+	Cert *SecretOrConfigMap `json:"cert,omitempty"`
+	// KeySecret is the private key of the client certificate
+	// +optional
+	KeySecret *corev1.SecretKeySelector `json:"keySecret,omitempty"`
+	// +optional
+	ServerName string `json:"serverName,omitempty"`
+	// +optional
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+}
+
+// SecretOrConfigMap selects a key of either a Secret or a ConfigMap, only one of them should be set
+type SecretOrConfigMap struct {
+	// +optional
+	Secret *corev1.SecretKeySelector `json:"secret,omitempty"`
+	// +optional
+	ConfigMap *corev1.ConfigMapKeySelector `json:"configMap,omitempty"`
 }
 
 //type Metric struct {
@@ -134,14 +295,57 @@ type AOMStatus struct {
 	// withModelKey
 	//PredictorMap     map[string]struct{} `json:"-"`
 	StatusCollectors []StatusCollector `json:"collectors"`
+	// StatusPredictors shows the trainings of the predictors
+	// +optional
+	StatusPredictors []StatusPredictor `json:"predictors,omitempty"`
 	// withModelKey
 	//PredictorHistory utils.ConcurrentMap[*PredictorHistory] `json:"-"`
 	Generation int64 `json:"generation"`
+	// Conditions roll up the health of the collectors, see the ConditionType constants
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 type StatusCollector struct {
 	Name       string `json:"name,omitempty"`
 	Unit       string `json:"unit,omitempty"`
 	Expression string `json:"expression,omitempty"`
+	// +optional
+	LastSuccess *metav1.Time `json:"lastSuccess,omitempty"`
+	// LastSample is the time stamp of the latest sample collected
+	// +optional
+	LastSample *metav1.Time `json:"lastSample,omitempty"`
+	// +optional
+	ConsecutiveFailures int `json:"consecutiveFailures,omitempty"`
+	// ConsecutiveEmpty counts the latest collects that returned no sample
+	// +optional
+	ConsecutiveEmpty int `json:"consecutiveEmpty,omitempty"`
+	// ErrorRate is the percentage of the latest collects that failed
+	// +optional
+	ErrorRate int `json:"errorRate,omitempty"`
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+	// +optional
+	Stale bool `json:"stale,omitempty"`
+}
+
+type StatusPredictor struct {
+	// Metric is the key of the metric in spec
+	Metric string `json:"metric"`
+	Type   string `json:"type"`
+	// Training is set while a training runs
+	// +optional
+	Training bool `json:"training,omitempty"`
+	// LastTrain is the time the latest successful training finished
+	// +optional
+	LastTrain *metav1.Time `json:"lastTrain,omitempty"`
+	// LastTrainError is the error of the latest training, empty once one succeeded
+	// +optional
+	LastTrainError string `json:"lastTrainError,omitempty"`
+	// Details are what the model reports, e.g. the parameters fitted by the training
+	// +optional
+	Details map[string]string `json:"details,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -1 +1,29 @@
 package v1
+
+// collector types
+const (
+	CollectorTypePrometheus  = "prometheus"
+	CollectorTypeMetricsAPI  = "metrics-api"
+	CollectorTypeFile        = "file"
+	CollectorTypeRemoteWrite = "remote-write"
+	CollectorTypeHTTP        = "http"
+	CollectorTypePods        = "pods"
+	CollectorTypeSQL         = "sql"
+	CollectorTypeExec        = "exec"
+)
+
+// stale policies
+const (
+	StalePolicyBlock  = "Block"
+	StalePolicyIgnore = "Ignore"
+)
+
+// condition types
+const (
+	// ConditionCollectorsHealthy is false when a worker keeps failing or returning no sample
+	ConditionCollectorsHealthy = "CollectorsHealthy"
+	// ConditionMetricsFresh is false when the latest sample of a metric is older than the stale threshold
+	ConditionMetricsFresh = "MetricsFresh"
+	// ConditionModelsValid is false when a model is skipped since it cannot be built from its attributes
+	ConditionModelsValid = "ModelsValid"
+)
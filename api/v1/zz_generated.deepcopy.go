@@ -23,6 +23,8 @@ package v1
 
 import (
 	"github.com/LL-res/AOM/common/basetype"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -89,7 +91,7 @@ func (in *AOMList) DeepCopyObject() runtime.Object {
 func (in *AOMSpec) DeepCopyInto(out *AOMSpec) {
 	*out = *in
 	out.ScaleTargetRef = in.ScaleTargetRef
-	out.Collector = in.Collector
+	in.Collector.DeepCopyInto(&out.Collector)
 	if in.Metrics != nil {
 		in, out := &in.Metrics, &out.Metrics
 		*out = make(map[string]basetype.Metric, len(*in))
@@ -132,7 +134,23 @@ func (in *AOMStatus) DeepCopyInto(out *AOMStatus) {
 	if in.StatusCollectors != nil {
 		in, out := &in.StatusCollectors, &out.StatusCollectors
 		*out = make([]StatusCollector, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.StatusPredictors != nil {
+		in, out := &in.StatusPredictors, &out.StatusPredictors
+		*out = make([]StatusPredictor, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
@@ -146,9 +164,63 @@ func (in *AOMStatus) DeepCopy() *AOMStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BasicAuth) DeepCopyInto(out *BasicAuth) {
+	*out = *in
+	in.Username.DeepCopyInto(&out.Username)
+	in.Password.DeepCopyInto(&out.Password)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BasicAuth.
+func (in *BasicAuth) DeepCopy() *BasicAuth {
+	if in == nil {
+		return nil
+	}
+	out := new(BasicAuth)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Collector) DeepCopyInto(out *Collector) {
 	*out = *in
+	if in.Auth != nil {
+		in, out := &in.Auth, &out.Auth
+		*out = new(CollectorAuth)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.File != nil {
+		in, out := &in.File, &out.File
+		*out = new(FileCollector)
+		**out = **in
+	}
+	if in.HTTP != nil {
+		in, out := &in.HTTP, &out.HTTP
+		*out = new(HTTPCollector)
+		**out = **in
+	}
+	if in.Pods != nil {
+		in, out := &in.Pods, &out.Pods
+		*out = new(PodsCollector)
+		**out = **in
+	}
+	if in.SQL != nil {
+		in, out := &in.SQL, &out.SQL
+		*out = new(SQLCollector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Exec != nil {
+		in, out := &in.Exec, &out.Exec
+		*out = new(ExecCollector)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Collector.
@@ -161,9 +233,148 @@ func (in *Collector) DeepCopy() *Collector {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CollectorAuth) DeepCopyInto(out *CollectorAuth) {
+	*out = *in
+	if in.BearerToken != nil {
+		in, out := &in.BearerToken, &out.BearerToken
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BasicAuth != nil {
+		in, out := &in.BasicAuth, &out.BasicAuth
+		*out = new(BasicAuth)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(TLSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CollectorAuth.
+func (in *CollectorAuth) DeepCopy() *CollectorAuth {
+	if in == nil {
+		return nil
+	}
+	out := new(CollectorAuth)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExecCollector) DeepCopyInto(out *ExecCollector) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExecCollector.
+func (in *ExecCollector) DeepCopy() *ExecCollector {
+	if in == nil {
+		return nil
+	}
+	out := new(ExecCollector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FileCollector) DeepCopyInto(out *FileCollector) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileCollector.
+func (in *FileCollector) DeepCopy() *FileCollector {
+	if in == nil {
+		return nil
+	}
+	out := new(FileCollector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPCollector) DeepCopyInto(out *HTTPCollector) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPCollector.
+func (in *HTTPCollector) DeepCopy() *HTTPCollector {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPCollector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodsCollector) DeepCopyInto(out *PodsCollector) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodsCollector.
+func (in *PodsCollector) DeepCopy() *PodsCollector {
+	if in == nil {
+		return nil
+	}
+	out := new(PodsCollector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SQLCollector) DeepCopyInto(out *SQLCollector) {
+	*out = *in
+	in.DSN.DeepCopyInto(&out.DSN)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SQLCollector.
+func (in *SQLCollector) DeepCopy() *SQLCollector {
+	if in == nil {
+		return nil
+	}
+	out := new(SQLCollector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretOrConfigMap) DeepCopyInto(out *SecretOrConfigMap) {
+	*out = *in
+	if in.Secret != nil {
+		in, out := &in.Secret, &out.Secret
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ConfigMap != nil {
+		in, out := &in.ConfigMap, &out.ConfigMap
+		*out = new(corev1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretOrConfigMap.
+func (in *SecretOrConfigMap) DeepCopy() *SecretOrConfigMap {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretOrConfigMap)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StatusCollector) DeepCopyInto(out *StatusCollector) {
 	*out = *in
+	if in.LastSuccess != nil {
+		in, out := &in.LastSuccess, &out.LastSuccess
+		*out = (*in).DeepCopy()
+	}
+	if in.LastSample != nil {
+		in, out := &in.LastSample, &out.LastSample
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StatusCollector.
@@ -175,3 +386,59 @@ func (in *StatusCollector) DeepCopy() *StatusCollector {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StatusPredictor) DeepCopyInto(out *StatusPredictor) {
+	*out = *in
+	if in.LastTrain != nil {
+		in, out := &in.LastTrain, &out.LastTrain
+		*out = (*in).DeepCopy()
+	}
+	if in.Details != nil {
+		in, out := &in.Details, &out.Details
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StatusPredictor.
+func (in *StatusPredictor) DeepCopy() *StatusPredictor {
+	if in == nil {
+		return nil
+	}
+	out := new(StatusPredictor)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TLSConfig) DeepCopyInto(out *TLSConfig) {
+	*out = *in
+	if in.CA != nil {
+		in, out := &in.CA, &out.CA
+		*out = new(SecretOrConfigMap)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Cert != nil {
+		in, out := &in.Cert, &out.Cert
+		*out = new(SecretOrConfigMap)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.KeySecret != nil {
+		in, out := &in.KeySecret, &out.KeySecret
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TLSConfig.
+func (in *TLSConfig) DeepCopy() *TLSConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(TLSConfig)
+	in.DeepCopyInto(out)
+	return out
+}
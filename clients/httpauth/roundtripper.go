@@ -0,0 +1,151 @@
+package httpauth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"github.com/LL-res/AOM/log"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const defaultRefreshInterval = time.Minute
+
+// Config holds the resolved credentials of a http connection
+type Config struct {
+	BearerToken string
+	Username    string
+	Password    string
+	// PEM encoded
+	CA                 []byte
+	Cert               []byte
+	Key                []byte
+	ServerName         string
+	InsecureSkipVerify bool
+	Headers            map[string]string
+}
+
+// Loader resolves the Config, e.g. by reading secrets
+type Loader func() (Config, error)
+
+// RoundTripper adds the credentials of Config to every request,
+// the Config is loaded again once the refresh interval passed so rotated credentials are picked up
+type RoundTripper struct {
+	load     Loader
+	refresh  time.Duration
+	mu       sync.Mutex
+	conf     Config
+	loadedAt time.Time
+	// rebuilt only when the tls part of conf changes
+	transport http.RoundTripper
+}
+
+func NewRoundTripper(load Loader, refresh time.Duration) (*RoundTripper, error) {
+	if refresh <= 0 {
+		refresh = defaultRefreshInterval
+	}
+	rt := &RoundTripper{
+		load:    load,
+		refresh: refresh,
+	}
+	conf, err := load()
+	if err != nil {
+		return nil, err
+	}
+	if err := rt.update(conf); err != nil {
+		return nil, err
+	}
+	return rt, nil
+}
+
+func (rt *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	conf, transport := rt.current()
+	req = req.Clone(req.Context())
+	for k, v := range conf.Headers {
+		req.Header.Set(k, v)
+	}
+	if conf.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+conf.BearerToken)
+	} else if conf.Username != "" {
+		req.SetBasicAuth(conf.Username, conf.Password)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err == nil && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+		// the credentials may have been rotated, read them again on the next request
+		rt.mu.Lock()
+		rt.loadedAt = time.Time{}
+		rt.mu.Unlock()
+	}
+	return resp, err
+}
+
+func (rt *RoundTripper) current() (Config, http.RoundTripper) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if time.Since(rt.loadedAt) < rt.refresh {
+		return rt.conf, rt.transport
+	}
+	conf, err := rt.load()
+	if err == nil {
+		err = rt.updateLocked(conf)
+	}
+	if err != nil {
+		// keep using the last working credentials
+		log.Logger.Error(err, "reload http credentials failed")
+		rt.loadedAt = time.Now()
+	}
+	return rt.conf, rt.transport
+}
+
+func (rt *RoundTripper) update(conf Config) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return rt.updateLocked(conf)
+}
+
+func (rt *RoundTripper) updateLocked(conf Config) error {
+	if rt.transport == nil || !sameTLS(rt.conf, conf) {
+		transport, err := newTransport(conf)
+		if err != nil {
+			return err
+		}
+		rt.transport = transport
+	}
+	rt.conf = conf
+	rt.loadedAt = time.Now()
+	return nil
+}
+
+func sameTLS(a, b Config) bool {
+	return reflect.DeepEqual(a.CA, b.CA) &&
+		reflect.DeepEqual(a.Cert, b.Cert) &&
+		reflect.DeepEqual(a.Key, b.Key) &&
+		a.ServerName == b.ServerName &&
+		a.InsecureSkipVerify == b.InsecureSkipVerify
+}
+
+func newTransport(conf Config) (http.RoundTripper, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         conf.ServerName,
+		InsecureSkipVerify: conf.InsecureSkipVerify,
+	}
+	if len(conf.CA) != 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(conf.CA) {
+			return nil, errors.New("no valid certificate found in ca bundle")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if len(conf.Cert) != 0 || len(conf.Key) != 0 {
+		cert, err := tls.X509KeyPair(conf.Cert, conf.Key)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
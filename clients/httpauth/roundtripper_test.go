@@ -0,0 +1,67 @@
+package httpauth
+
+import (
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRoundTripper(t *testing.T) {
+	var gotAuth, gotTenant string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotTenant = r.Header.Get("X-Scope-OrgID")
+	}))
+	defer server.Close()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	token := "first"
+	rt, err := NewRoundTripper(func() (Config, error) {
+		return Config{
+			BearerToken: token,
+			CA:          ca,
+			Headers:     map[string]string{"X-Scope-OrgID": "tenant-a"},
+		}, nil
+	}, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: rt}
+	tests := []struct {
+		token string
+		want  string
+	}{
+		{token: "first", want: "Bearer first"},
+		// the rotated token is picked up after the refresh interval
+		{token: "second", want: "Bearer second"},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			token = tt.token
+			time.Sleep(20 * time.Millisecond)
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if gotAuth != tt.want {
+				t.Errorf("got authorization %q, want %q", gotAuth, tt.want)
+			}
+			if gotTenant != "tenant-a" {
+				t.Errorf("got tenant %q", gotTenant)
+			}
+		})
+	}
+}
+
+func TestRoundTripperInvalidCA(t *testing.T) {
+	_, err := NewRoundTripper(func() (Config, error) {
+		return Config{CA: []byte("not a pem")}, nil
+	}, 0)
+	if err == nil {
+		t.Error("expected an error for an invalid ca bundle")
+	}
+}
@@ -2,7 +2,10 @@ package k8s
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	cached "k8s.io/client-go/discovery/cached"
@@ -104,3 +107,91 @@ func (c *Client) SetReplica(namespace string, scaleTargetRef autoscalingv2.Cross
 
 	return err
 }
+
+// GetSecretKey reads the value of one key in a secret
+func (c *Client) GetSecretKey(namespace string, selector corev1.SecretKeySelector) ([]byte, error) {
+	secret, err := c.ClientSet.CoreV1().Secrets(namespace).Get(context.TODO(), selector.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	val, ok := secret.Data[selector.Key]
+	if !ok {
+		return nil, fmt.Errorf("key [%s] not found in secret [%s/%s]", selector.Key, namespace, selector.Name)
+	}
+	return val, nil
+}
+
+// GetConfigMapKey reads the value of one key in a configmap
+func (c *Client) GetConfigMapKey(namespace string, selector corev1.ConfigMapKeySelector) ([]byte, error) {
+	configMap, err := c.ClientSet.CoreV1().ConfigMaps(namespace).Get(context.TODO(), selector.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if val, ok := configMap.Data[selector.Key]; ok {
+		return []byte(val), nil
+	}
+	if val, ok := configMap.BinaryData[selector.Key]; ok {
+		return val, nil
+	}
+	return nil, fmt.Errorf("key [%s] not found in configmap [%s/%s]", selector.Key, namespace, selector.Name)
+}
+
+// GetSelector returns the label selector of the pods managed by the scale target
+func (c *Client) GetSelector(namespace string, scaleTargetRef autoscalingv2.CrossVersionObjectReference) (string, error) {
+	gvk := schema.FromAPIVersionAndKind(scaleTargetRef.APIVersion, "")
+	scaleObj, err := c.ScaleGetter.Scales(namespace).Get(context.TODO(), schema.GroupResource{
+		Group:    gvk.Group,
+		Resource: scaleTargetRef.Kind,
+	}, scaleTargetRef.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	if scaleObj.Status.Selector == "" {
+		return "", fmt.Errorf("scale target [%s/%s] exposes no selector", namespace, scaleTargetRef.Name)
+	}
+	return scaleObj.Status.Selector, nil
+}
+
+// ListPods returns the pods matching selector
+func (c *Client) ListPods(namespace string, selector string) ([]corev1.Pod, error) {
+	list, err := c.ClientSet.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: selector,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// PodMetrics is the subset of metrics.k8s.io/v1beta1 PodMetrics used by aom
+type PodMetrics struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Timestamp         metav1.Time        `json:"timestamp"`
+	Window            metav1.Duration    `json:"window"`
+	Containers        []ContainerMetrics `json:"containers"`
+}
+
+type ContainerMetrics struct {
+	Name  string              `json:"name"`
+	Usage corev1.ResourceList `json:"usage"`
+}
+
+type podMetricsList struct {
+	Items []PodMetrics `json:"items"`
+}
+
+// ListPodMetrics reads the resource usage of the selected pods from the metrics api
+func (c *Client) ListPodMetrics(namespace string, selector string) ([]PodMetrics, error) {
+	body, err := c.ClientSet.RESTClient().Get().
+		AbsPath("/apis/metrics.k8s.io/v1beta1/namespaces", namespace, "pods").
+		Param("labelSelector", selector).
+		DoRaw(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	list := podMetricsList{}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
@@ -0,0 +1,168 @@
+package collector
+
+import (
+	"fmt"
+	"github.com/LL-res/AOM/log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Buffer keeps the metrics collected by a worker until they are sent, at most as long as the raw history,
+// workers embed it to share the Send, DataCap and Window behaviour
+type Buffer struct {
+	data []Metric
+	// kept regardless of Send, created on first use with HistoryRetention
+	history *History
+	// the last metric appended, kept after Send
+	latest    Metric
+	hasLatest bool
+	mu        sync.Mutex
+	// optional, checkpoints every change of history
+	persister Persister
+	key       string
+}
+
+func (b *Buffer) Append(metrics ...Metric) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.data = append(b.data, metrics...)
+	added := make(map[time.Duration][]Metric)
+	b.getHistory().add(metrics, func(resolution time.Duration, m Metric) {
+		added[resolution] = append(added[resolution], m)
+	})
+	if len(metrics) != 0 {
+		b.latest, b.hasLatest = metrics[len(metrics)-1], true
+	}
+	b.trim()
+	if b.persister != nil {
+		b.checkpoint(added)
+	}
+}
+
+// trim drops the unsent metrics older than the raw history keeps, so that the buffer of a worker
+// read through Window only, which nobody sends, does not grow without bound. It must be called with mu held
+func (b *Buffer) trim() {
+	retention := b.getHistory().tier(ResolutionRaw).retention
+	if retention <= 0 || len(b.data) == 0 {
+		return
+	}
+	oldest := b.data[len(b.data)-1].TimeStamp.Add(-retention)
+	cut := sort.Search(len(b.data), func(i int) bool {
+		return !b.data[i].TimeStamp.Before(oldest)
+	})
+	b.data = b.data[cut:]
+}
+
+// checkpoint appends the points that joined the tiers of history, a tier is written anew once more points were
+// pruned from it than it keeps, so that the checkpoint does not outgrow the history. It must be called with mu held
+func (b *Buffer) checkpoint(added map[time.Duration][]Metric) {
+	for _, t := range b.history.tiers {
+		key := checkpointKey(b.key, t.resolution)
+		var err error
+		switch {
+		case t.pruned > len(t.points):
+			if err = b.persister.Reset(key); err == nil {
+				err = b.persister.Append(key, t.points...)
+			}
+			t.pruned = 0
+		case len(added[t.resolution]) != 0:
+			err = b.persister.Append(key, added[t.resolution]...)
+		}
+		if err != nil {
+			log.Logger.Error(err, "checkpoint metrics failed", "key", key)
+		}
+	}
+}
+
+// Send returns all the buffered metrics and empties the buffer
+func (b *Buffer) Send() []Metric {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	res := make([]Metric, len(b.data))
+	copy(res, b.data)
+	b.data = make([]Metric, 0)
+	return res
+}
+
+// Latest returns the last metric appended, whether it was sent or not
+func (b *Buffer) Latest() (Metric, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.latest, b.hasLatest
+}
+
+// Window returns the latest n metrics at resolution, whether they were sent or not
+func (b *Buffer) Window(resolution time.Duration, n int) ([]Metric, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.getHistory().Window(resolution, n)
+}
+
+// getHistory must be called with mu held
+func (b *Buffer) getHistory() *History {
+	if b.history == nil {
+		b.history = NewHistory(HistoryRetention)
+	}
+	return b.history
+}
+
+func (b *Buffer) DataCap() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.data)
+}
+
+// Persist restores the history checkpointed under key and checkpoints every later change,
+// the restored raw metrics are buffered for Send as well
+func (b *Buffer) Persist(p Persister, key string) error {
+	restored := make(map[time.Duration][]Metric)
+	for _, resolution := range Resolutions {
+		metrics, err := p.Load(checkpointKey(key, resolution))
+		if err != nil {
+			return err
+		}
+		restored[resolution] = metrics
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	history := b.getHistory()
+	for _, resolution := range Resolutions {
+		history.restore(resolution, restored[resolution])
+	}
+	// the raw metrics refill the open buckets of the rollups, a bucket closed by them is checkpointed below
+	raw := restored[ResolutionRaw]
+	added := make(map[time.Duration][]Metric)
+	history.add(raw, func(resolution time.Duration, m Metric) {
+		if resolution != ResolutionRaw {
+			added[resolution] = append(added[resolution], m)
+		}
+	})
+	b.data = append(raw, b.data...)
+	b.trim()
+	b.persister = p
+	b.key = key
+	b.checkpoint(added)
+	if len(raw) != 0 {
+		log.Logger.Info("restore metrics from checkpoint", "key", key, "num", len(raw))
+	}
+	return nil
+}
+
+// DeleteCheckpoint removes the history a worker checkpointed under key
+func DeleteCheckpoint(p Persister, key string) error {
+	for _, resolution := range Resolutions {
+		if err := p.Delete(checkpointKey(key, resolution)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkpointKey is the key the tier at resolution is checkpointed under, the raw metrics keep key itself
+func checkpointKey(key string, resolution time.Duration) string {
+	if resolution == ResolutionRaw {
+		return key
+	}
+	return fmt.Sprintf("%s@%s", key, resolution)
+}
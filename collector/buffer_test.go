@@ -0,0 +1,73 @@
+package collector
+
+import (
+	"github.com/LL-res/AOM/log"
+	"testing"
+	"time"
+)
+
+type memPersister map[string][]Metric
+
+func (m memPersister) Load(key string) ([]Metric, error) { return m[key], nil }
+func (m memPersister) Append(key string, metrics ...Metric) error {
+	m[key] = append(m[key], metrics...)
+	return nil
+}
+func (m memPersister) Reset(key string) error  { m[key] = nil; return nil }
+func (m memPersister) Delete(key string) error { delete(m, key); return nil }
+
+func TestBufferPersist(t *testing.T) {
+	log.Init()
+	start := time.Now().Truncate(time.Minute)
+	p := memPersister{"key": {{Value: 1, TimeStamp: start}}}
+	b := &Buffer{}
+	if err := b.Persist(p, "key"); err != nil {
+		t.Fatal(err)
+	}
+	if b.DataCap() != 1 {
+		t.Fatalf("checkpointed metrics not restored, cap %d", b.DataCap())
+	}
+	b.Append(Metric{Value: 3, TimeStamp: start.Add(30 * time.Second)}, Metric{Value: 5, TimeStamp: start.Add(time.Minute)})
+	if len(p["key"]) != 3 {
+		t.Errorf("appended metrics not checkpointed, %v", p["key"])
+	}
+	if sent := b.Send(); len(sent) != 3 {
+		t.Errorf("got %v, want 3 metrics", sent)
+	}
+	if len(p["key"]) != 3 {
+		t.Errorf("sent metrics dropped from the checkpoint, %v", p["key"])
+	}
+	if rollups := p[checkpointKey("key", ResolutionMinute)]; len(rollups) != 1 || rollups[0].Value != 2 {
+		t.Errorf("closed rollup not checkpointed, %v", rollups)
+	}
+	// a worker created after a restart gets the history back
+	restored := &Buffer{}
+	if err := restored.Persist(p, "key"); err != nil {
+		t.Fatal(err)
+	}
+	for resolution, want := range map[time.Duration]int{ResolutionRaw: 3, ResolutionMinute: 1} {
+		if _, err := restored.Window(resolution, want); err != nil {
+			t.Errorf("history at %s not restored: %v", resolution, err)
+		}
+	}
+	// the open bucket is refilled from the raw metrics rather than closed twice
+	restored.Append(Metric{Value: 7, TimeStamp: start.Add(2 * time.Minute)})
+	if got, _ := restored.Window(ResolutionMinute, 2); len(got) != 2 || got[1].Value != 5 {
+		t.Errorf("got rollups %v, want 2 and 5", got)
+	}
+	if err := DeleteCheckpoint(p, "key"); err != nil || len(p) != 0 {
+		t.Errorf("checkpoint left after delete: %v, %v", p, err)
+	}
+}
+
+func TestBufferTrim(t *testing.T) {
+	start := time.Now()
+	b := &Buffer{history: NewHistory(Retention{Raw: time.Minute})}
+	for i := 0; i < 10; i++ {
+		b.Append(Metric{Value: float64(i), TimeStamp: start.Add(time.Duration(i) * 15 * time.Second)})
+	}
+	// nothing sends the buffer, it keeps the last minute only
+	if got := b.Send(); len(got) != 5 || got[0].Value != 5 {
+		t.Errorf("got %v, want the metrics 5 to 9", got)
+	}
+}
@@ -0,0 +1,267 @@
+package checkpoint
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/LL-res/AOM/collector"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Options decides how much history is kept
+type Options struct {
+	// metrics older than Retention are dropped, 0 keeps everything
+	Retention time.Duration
+	// only the latest MaxPoints metrics of a key are kept, 0 keeps everything
+	MaxPoints int
+	// a file is rewritten once it holds CompactThreshold records more than the metrics it keeps
+	CompactThreshold int
+}
+
+const defaultCompactThreshold = 1000
+
+// Store checkpoints the history of workers into append-only files, one file for each key
+type Store struct {
+	dir  string
+	opts Options
+	mu   sync.Mutex
+	logs map[string]*appendLog
+}
+
+// record is one line of an append-only file
+type record struct {
+	// unix nano
+	TimeStamp int64   `json:"t,omitempty"`
+	Value     float64 `json:"v,omitempty"`
+	// the metrics before this record were dropped
+	Reset bool `json:"reset,omitempty"`
+}
+
+type appendLog struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	live []collector.Metric
+	// number of records in file
+	records int
+}
+
+func NewStore(dir string, opts Options) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if opts.CompactThreshold <= 0 {
+		opts.CompactThreshold = defaultCompactThreshold
+	}
+	return &Store{
+		dir:  dir,
+		opts: opts,
+		logs: make(map[string]*appendLog),
+	}, nil
+}
+
+func (s *Store) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".jsonl")
+}
+
+// open returns the log of key, reading the file the first time
+func (s *Store) open(key string) (*appendLog, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if l, ok := s.logs[key]; ok {
+		return l, nil
+	}
+	l := &appendLog{path: s.path(key)}
+	if err := l.read(); err != nil {
+		return nil, err
+	}
+	// drop what is out of retention right away
+	if err := l.compact(s.opts); err != nil {
+		return nil, err
+	}
+	s.logs[key] = l
+	return l, nil
+}
+
+func (s *Store) Load(key string) ([]collector.Metric, error) {
+	l, err := s.open(key)
+	if err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	res := make([]collector.Metric, len(l.live))
+	copy(res, l.live)
+	return res, nil
+}
+
+func (s *Store) Append(key string, metrics ...collector.Metric) error {
+	l, err := s.open(key)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	records := make([]record, 0, len(metrics))
+	for _, m := range metrics {
+		records = append(records, record{TimeStamp: m.TimeStamp.UnixNano(), Value: m.Value})
+	}
+	if err := l.write(records...); err != nil {
+		return err
+	}
+	l.live = append(l.live, metrics...)
+	return l.compactIfNeeded(s.opts)
+}
+
+func (s *Store) Reset(key string) error {
+	l, err := s.open(key)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.write(record{Reset: true}); err != nil {
+		return err
+	}
+	l.live = nil
+	return l.compactIfNeeded(s.opts)
+}
+
+func (s *Store) Delete(key string) error {
+	s.mu.Lock()
+	l, ok := s.logs[key]
+	delete(s.logs, key)
+	s.mu.Unlock()
+	if ok {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.file != nil {
+			l.file.Close()
+			l.file = nil
+		}
+	}
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// read replays the records of the file, a missing file is an empty log
+func (l *appendLog) read() error {
+	file, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		r := record{}
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			// the last line may be cut off by a crash, everything before it is still valid
+			break
+		}
+		l.records++
+		if r.Reset {
+			l.live = nil
+			continue
+		}
+		l.live = append(l.live, collector.Metric{
+			Value:     r.Value,
+			TimeStamp: time.Unix(0, r.TimeStamp),
+		})
+	}
+	return scanner.Err()
+}
+
+func (l *appendLog) write(records ...record) error {
+	if l.file == nil {
+		file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		l.file = file
+	}
+	w := bufio.NewWriter(l.file)
+	for _, r := range records {
+		line, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+	l.records += len(records)
+	return w.Flush()
+}
+
+func (l *appendLog) compactIfNeeded(opts Options) error {
+	if l.records-len(l.live) < opts.CompactThreshold &&
+		(opts.MaxPoints <= 0 || len(l.live) <= opts.MaxPoints+opts.CompactThreshold) {
+		return nil
+	}
+	return l.compact(opts)
+}
+
+// compact applies the retention and rewrites the file with the metrics kept only
+func (l *appendLog) compact(opts Options) error {
+	live := l.live
+	if opts.Retention > 0 {
+		deadline := time.Now().Add(-opts.Retention)
+		i := 0
+		for i < len(live) && live[i].TimeStamp.Before(deadline) {
+			i++
+		}
+		live = live[i:]
+	}
+	if opts.MaxPoints > 0 && len(live) > opts.MaxPoints {
+		live = live[len(live)-opts.MaxPoints:]
+	}
+	if len(live) == l.records && len(live) == len(l.live) {
+		return nil
+	}
+	tmp := l.path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	for _, m := range live {
+		line, err := json.Marshal(record{TimeStamp: m.TimeStamp.UnixNano(), Value: m.Value})
+		if err != nil {
+			file.Close()
+			return err
+		}
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return fmt.Errorf("replace checkpoint failed: %w", err)
+	}
+	l.live = append([]collector.Metric(nil), live...)
+	l.records = len(live)
+	return nil
+}
@@ -0,0 +1,143 @@
+package checkpoint
+
+import (
+	"fmt"
+	"github.com/LL-res/AOM/collector"
+	"os"
+	"testing"
+	"time"
+)
+
+const key = "default/aom-sample/http_requests$num$sum(http_requests_total)"
+
+func points(start time.Time, values ...float64) []collector.Metric {
+	res := make([]collector.Metric, 0, len(values))
+	for i, v := range values {
+		res = append(res, collector.Metric{Value: v, TimeStamp: start.Add(time.Duration(i) * time.Second)})
+	}
+	return res
+}
+
+func values(metrics []collector.Metric) []float64 {
+	res := make([]float64, 0, len(metrics))
+	for _, m := range metrics {
+		res = append(res, m.Value)
+	}
+	return res
+}
+
+func TestStore(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		opts Options
+		do   func(s *Store) error
+		want []float64
+	}{
+		{
+			do: func(s *Store) error {
+				return s.Append(key, points(now, 1, 2, 3)...)
+			},
+			want: []float64{1, 2, 3},
+		},
+		{
+			do: func(s *Store) error {
+				if err := s.Append(key, points(now, 1, 2)...); err != nil {
+					return err
+				}
+				if err := s.Reset(key); err != nil {
+					return err
+				}
+				return s.Append(key, points(now, 3)...)
+			},
+			want: []float64{3},
+		},
+		{
+			opts: Options{Retention: time.Hour},
+			do: func(s *Store) error {
+				return s.Append(key, append(points(now.Add(-2*time.Hour), 1, 2), points(now, 3)...)...)
+			},
+			want: []float64{3},
+		},
+		{
+			opts: Options{MaxPoints: 2, CompactThreshold: 1},
+			do: func(s *Store) error {
+				return s.Append(key, points(now, 1, 2, 3, 4)...)
+			},
+			want: []float64{3, 4},
+		},
+		{
+			do: func(s *Store) error {
+				if err := s.Append(key, points(now, 1)...); err != nil {
+					return err
+				}
+				return s.Delete(key)
+			},
+			want: []float64{},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			dir := t.TempDir()
+			s, err := NewStore(dir, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.do(s); err != nil {
+				t.Fatal(err)
+			}
+			// a new store on the same directory stands for a restarted operator
+			restarted, err := NewStore(dir, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := restarted.Load(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(values(got)) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", values(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestCompact(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewStore(dir, Options{CompactThreshold: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		if err := s.Append(key, points(time.Now(), float64(i))...); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Reset(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Append(key, points(time.Now(), 42)...); err != nil {
+		t.Fatal(err)
+	}
+	l, err := s.open(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.records > 12 {
+		t.Errorf("file not compacted, %d records", l.records)
+	}
+	// a line cut off by a crash is ignored
+	f, err := os.OpenFile(s.path(key), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"t":12`)
+	f.Close()
+	restarted, _ := NewStore(dir, Options{})
+	got, err := restarted.Load(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Value != 42 {
+		t.Errorf("got %v, want [42]", values(got))
+	}
+}
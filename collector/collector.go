@@ -3,6 +3,7 @@ package collector
 import (
 	"fmt"
 	"github.com/LL-res/AOM/common/basetype"
+	"net/http"
 
 	"sync"
 	"time"
@@ -22,14 +23,57 @@ type WorkerMap[T any] struct {
 type Collector interface {
 	SetServerAddress(url string) error
 	ListMetricTypes() []basetype.Metric
-	AddCustomMetrics(metric basetype.Metric)
+	// AddCustomMetrics registers metric, query is what the collector runs for it,
+	// i.e. metric.Query rendered by RenderQuery
+	AddCustomMetrics(metric basetype.Metric, query string)
 	CreateWorker(MetricType basetype.Metric) (MetricCollector, error)
 }
+
+// HTTPCollector is implemented by the collectors talking to their server over http,
+// the round tripper carries the auth, tls and header settings of the connection
+type HTTPCollector interface {
+	SetRoundTripper(rt http.RoundTripper) error
+}
+
+// DataSourceCollector is implemented by the collectors reaching a database through a driver and a dsn,
+// dsn is called again once refresh passed, so that a rotated password is picked up
+type DataSourceCollector interface {
+	SetDataSource(driver string, dsn func() (string, error), refresh time.Duration) error
+}
+
+// RemovableCollector is implemented by the collectors holding on to their workers, which are dropped once stopped
+type RemovableCollector interface {
+	RemoveWorker(noModelKey string)
+}
+
+// LatestCollector is implemented by the workers that can tell their last sample without sending it
+type LatestCollector interface {
+	Latest() (Metric, bool)
+}
+
+// Persister checkpoints the history of workers so that it survives restarts
+type Persister interface {
+	// Load returns the metrics checkpointed under key
+	Load(key string) ([]Metric, error)
+	Append(key string, metrics ...Metric) error
+	// Reset drops the metrics under key, the ones appended afterwards are kept
+	Reset(key string) error
+	// Delete removes the checkpoint of a worker that is gone
+	Delete(key string) error
+}
+
+// PersistentCollector is implemented by the workers whose history can be checkpointed
+type PersistentCollector interface {
+	Persist(p Persister, key string) error
+}
 type MetricCollector interface {
 	Collect() error
 	Send() []Metric
 	NoModelKey() string
 	DataCap() int
+	// Window returns the latest n metrics at resolution, see ValidResolution for the resolutions kept,
+	// errs.NO_SUFFICENT_DATA is returned while there are fewer
+	Window(resolution time.Duration, n int) ([]Metric, error)
 }
 type CollectorBase struct {
 	//key: the name of  supported metric type,value: the promql to get key metric type
@@ -0,0 +1,116 @@
+package derived_collector
+
+import (
+	"fmt"
+	"github.com/LL-res/AOM/collector"
+	"github.com/LL-res/AOM/common/basetype"
+	"time"
+)
+
+// Replicas is the name of the variable holding the live replica count of the scale target
+const Replicas = "replicas"
+
+// ReplicaFunc returns the live replica count of the scale target
+type ReplicaFunc func() (int32, error)
+
+type worker struct {
+	collector.MetricType
+	collector.Buffer
+	key  string
+	expr *Expr
+	// variable name -> the worker of the collected metric
+	sources  map[string]collector.LatestCollector
+	replicas ReplicaFunc
+	// the latest samples of the sources are aligned when they are at most this far apart
+	tolerance     time.Duration
+	lastTimeStamp time.Time
+}
+
+// NewWorker creates the worker of a derived metric, sources maps the variables of the expression
+// to the workers of the collected metrics they refer to
+func NewWorker(metric basetype.Metric, sources map[string]collector.MetricCollector, replicas ReplicaFunc, tolerance time.Duration) (collector.MetricCollector, error) {
+	expr, err := Parse(metric.Expression)
+	if err != nil {
+		return nil, err
+	}
+	latest := make(map[string]collector.LatestCollector, len(sources))
+	for _, v := range expr.Vars() {
+		if v == Replicas {
+			continue
+		}
+		source, ok := sources[v]
+		if !ok {
+			return nil, fmt.Errorf("derived metric [%s] refers to unknown metric [%s]", metric.Name, v)
+		}
+		l, ok := source.(collector.LatestCollector)
+		if !ok {
+			return nil, fmt.Errorf("the worker of metric [%s] can not be derived from", v)
+		}
+		latest[v] = l
+	}
+	return &worker{
+		MetricType: collector.MetricType{
+			Name: metric.Name,
+			Unit: metric.Unit,
+		},
+		key:       metric.NoModelKey(),
+		expr:      expr,
+		sources:   latest,
+		replicas:  replicas,
+		tolerance: tolerance,
+	}, nil
+}
+
+// Collect evaluates the expression on the latest samples of the sources,
+// nothing is appended until every source has a new sample
+func (w *worker) Collect() error {
+	vars := make(map[string]float64, len(w.sources)+1)
+	var oldest, newest time.Time
+	for name, source := range w.sources {
+		sample, ok := source.Latest()
+		if !ok {
+			return nil
+		}
+		vars[name] = sample.Value
+		if oldest.IsZero() || sample.TimeStamp.Before(oldest) {
+			oldest = sample.TimeStamp
+		}
+		if sample.TimeStamp.After(newest) {
+			newest = sample.TimeStamp
+		}
+	}
+	if newest.Sub(oldest) > w.tolerance {
+		return fmt.Errorf("samples of derived metric [%s] are not aligned, %s apart", w.Name, newest.Sub(oldest))
+	}
+	if newest.IsZero() {
+		// derived from replicas only
+		newest = time.Now()
+	}
+	if !newest.After(w.lastTimeStamp) {
+		return nil
+	}
+	for _, v := range w.expr.Vars() {
+		if v != Replicas {
+			continue
+		}
+		replicas, err := w.replicas()
+		if err != nil {
+			return err
+		}
+		vars[Replicas] = float64(replicas)
+	}
+	value, err := w.expr.Eval(vars)
+	if err != nil {
+		return fmt.Errorf("evaluate derived metric [%s] failed: %w", w.Name, err)
+	}
+	w.lastTimeStamp = newest
+	w.Append(collector.Metric{
+		Value:     value,
+		TimeStamp: newest,
+	})
+	return nil
+}
+
+func (w *worker) NoModelKey() string {
+	return w.key
+}
@@ -0,0 +1,114 @@
+package derived_collector
+
+import (
+	"fmt"
+	"github.com/LL-res/AOM/collector"
+	"github.com/LL-res/AOM/common/basetype"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	vars := map[string]float64{"requests": 120, "queue_depth": 30, "consumers": 4, "replicas": 3}
+	tests := []struct {
+		expr    string
+		want    float64
+		vars    []string
+		wantErr bool
+	}{
+		{expr: "requests / replicas", want: 40, vars: []string{"replicas", "requests"}},
+		{expr: "queue_depth / consumers", want: 7.5, vars: []string{"consumers", "queue_depth"}},
+		{expr: "(requests + queue_depth) * 2 - -1", want: 301, vars: []string{"queue_depth", "requests"}},
+		{expr: "requests * 1e-2 + 0.5", want: 1.7, vars: []string{"requests"}},
+		{expr: "2 + 3 * 4", want: 14, vars: []string{}},
+		{expr: "requests / (replicas - 3)", wantErr: true},
+		{expr: "unknown + 1", wantErr: true},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			expr, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := expr.Eval(vars)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if tt.wantErr {
+				return
+			}
+			if fmt.Sprintf("%.6f", got) != fmt.Sprintf("%.6f", tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if fmt.Sprint(expr.Vars()) != fmt.Sprint(tt.vars) {
+				t.Errorf("got vars %v, want %v", expr.Vars(), tt.vars)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for i, expr := range []string{"", "a +", "(a", "a b", "a % b", "1..2"} {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			if _, err := Parse(expr); err == nil {
+				t.Errorf("expected an error for %q", expr)
+			}
+		})
+	}
+}
+
+type source struct {
+	collector.Buffer
+}
+
+func (s *source) Collect() error     { return nil }
+func (s *source) NoModelKey() string { return "" }
+
+func TestCollect(t *testing.T) {
+	now := time.Now()
+	requests, queue := &source{}, &source{}
+	metric := basetype.Metric{Name: "per_replica", Kind: basetype.MetricKindDerived, Expression: "(requests + queue) / replicas"}
+	w, err := NewWorker(metric, map[string]collector.MetricCollector{"requests": requests, "queue": queue}, func() (int32, error) {
+		return 4, nil
+	}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := []struct {
+		requests, queue *collector.Metric
+		want            int
+		wantErr         bool
+	}{
+		// queue has no sample yet
+		{requests: &collector.Metric{Value: 100, TimeStamp: now}, want: 0},
+		{queue: &collector.Metric{Value: 20, TimeStamp: now.Add(100 * time.Millisecond)}, want: 1},
+		// nothing new
+		{want: 1},
+		// not aligned
+		{requests: &collector.Metric{Value: 100, TimeStamp: now.Add(5 * time.Second)}, want: 1, wantErr: true},
+		{queue: &collector.Metric{Value: 20, TimeStamp: now.Add(5 * time.Second)}, want: 2},
+	}
+	for i, step := range steps {
+		if step.requests != nil {
+			requests.Append(*step.requests)
+		}
+		if step.queue != nil {
+			queue.Append(*step.queue)
+		}
+		err := w.Collect()
+		if (err != nil) != step.wantErr {
+			t.Errorf("step %d: unexpected error %v", i, err)
+		}
+		if w.DataCap() != step.want {
+			t.Errorf("step %d: got %d samples, want %d", i, w.DataCap(), step.want)
+		}
+	}
+	for _, m := range w.Send() {
+		if m.Value != 30 {
+			t.Errorf("got %v, want 30", m.Value)
+		}
+	}
+	if _, err := NewWorker(basetype.Metric{Expression: "missing / replicas"}, nil, nil, time.Second); err == nil {
+		t.Error("expected an error for an unknown source")
+	}
+}
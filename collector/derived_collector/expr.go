@@ -0,0 +1,251 @@
+package derived_collector
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"unicode"
+)
+
+// Expr is a parsed arithmetic expression over named variables,
+// it supports numbers, variables, + - * /, unary minus and parentheses
+type Expr struct {
+	root node
+	vars []string
+}
+
+type node interface {
+	eval(vars map[string]float64) (float64, error)
+}
+
+type number float64
+
+type variable string
+
+type unary struct {
+	x node
+}
+
+type binary struct {
+	op   byte
+	x, y node
+}
+
+func (n number) eval(map[string]float64) (float64, error) {
+	return float64(n), nil
+}
+
+func (v variable) eval(vars map[string]float64) (float64, error) {
+	val, ok := vars[string(v)]
+	if !ok {
+		return 0, fmt.Errorf("variable [%s] has no value", string(v))
+	}
+	return val, nil
+}
+
+func (u unary) eval(vars map[string]float64) (float64, error) {
+	x, err := u.x.eval(vars)
+	return -x, err
+}
+
+func (b binary) eval(vars map[string]float64) (float64, error) {
+	x, err := b.x.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	y, err := b.y.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	switch b.op {
+	case '+':
+		return x + y, nil
+	case '-':
+		return x - y, nil
+	case '*':
+		return x * y, nil
+	default:
+		if y == 0 {
+			return 0, errors.New("division by zero")
+		}
+		return x / y, nil
+	}
+}
+
+// Eval computes the expression, every variable of Vars must have a value
+func (e *Expr) Eval(vars map[string]float64) (float64, error) {
+	return e.root.eval(vars)
+}
+
+// Vars returns the sorted names of the variables the expression refers to
+func (e *Expr) Vars() []string {
+	return e.vars
+}
+
+// Parse parses an expression such as (requests + errors) / replicas,
+// a variable is made of letters, digits and underscores and does not start with a digit
+func Parse(s string) (*Expr, error) {
+	p := &parser{src: s, vars: make(map[string]struct{})}
+	p.next()
+	root, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok != tokEOF {
+		return nil, p.errorf("unexpected %q", p.text)
+	}
+	vars := make([]string, 0, len(p.vars))
+	for v := range p.vars {
+		vars = append(vars, v)
+	}
+	sort.Strings(vars)
+	return &Expr{root: root, vars: vars}, nil
+}
+
+const (
+	tokEOF = iota
+	tokNumber
+	tokIdent
+	tokOp
+	tokInvalid
+)
+
+type parser struct {
+	src  string
+	pos  int
+	tok  int
+	text string
+	// position of the current token
+	start int
+	vars  map[string]struct{}
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("invalid expression [%s] at %d: %s", p.src, p.start, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) next() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+	p.start = p.pos
+	if p.pos == len(p.src) {
+		p.tok, p.text = tokEOF, ""
+		return
+	}
+	c := p.src[p.pos]
+	switch {
+	case c == '+' || c == '-' || c == '*' || c == '/' || c == '(' || c == ')':
+		p.pos++
+		p.tok = tokOp
+	case isDigit(c) || c == '.':
+		p.skip(func(c byte) bool { return isDigit(c) || c == '.' })
+		// exponent, e.g. 1e-3
+		if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+			p.pos++
+			if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+				p.pos++
+			}
+			p.skip(isDigit)
+		}
+		p.tok = tokNumber
+	case isLetter(c):
+		p.skip(func(c byte) bool { return isLetter(c) || isDigit(c) })
+		p.tok = tokIdent
+	default:
+		p.pos++
+		p.tok = tokInvalid
+	}
+	p.text = p.src[p.start:p.pos]
+}
+
+func (p *parser) skip(accept func(c byte) bool) {
+	for p.pos < len(p.src) && accept(p.src[p.pos]) {
+		p.pos++
+	}
+}
+
+// sum = product { ("+" | "-") product }
+func (p *parser) parseSum() (node, error) {
+	x, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok == tokOp && (p.text == "+" || p.text == "-") {
+		op := p.text[0]
+		p.next()
+		y, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		x = binary{op: op, x: x, y: y}
+	}
+	return x, nil
+}
+
+// product = factor { ("*" | "/") factor }
+func (p *parser) parseProduct() (node, error) {
+	x, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok == tokOp && (p.text == "*" || p.text == "/") {
+		op := p.text[0]
+		p.next()
+		y, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		x = binary{op: op, x: x, y: y}
+	}
+	return x, nil
+}
+
+// factor = number | variable | "-" factor | "(" sum ")"
+func (p *parser) parseFactor() (node, error) {
+	switch {
+	case p.tok == tokNumber:
+		val, err := strconv.ParseFloat(p.text, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", p.text)
+		}
+		p.next()
+		return number(val), nil
+	case p.tok == tokIdent:
+		name := p.text
+		p.vars[name] = struct{}{}
+		p.next()
+		return variable(name), nil
+	case p.tok == tokOp && p.text == "-":
+		p.next()
+		x, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		return unary{x: x}, nil
+	case p.tok == tokOp && p.text == "(":
+		p.next()
+		x, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok != tokOp || p.text != ")" {
+			return nil, p.errorf("missing )")
+		}
+		p.next()
+		return x, nil
+	case p.tok == tokEOF:
+		return nil, p.errorf("unexpected end")
+	default:
+		return nil, p.errorf("unexpected %q", p.text)
+	}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
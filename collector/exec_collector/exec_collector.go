@@ -0,0 +1,298 @@
+package exec_collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/LL-res/AOM/collector"
+	"github.com/LL-res/AOM/common/basetype"
+	"github.com/LL-res/AOM/common/errs"
+	"math"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTimeout        = 10 * time.Second
+	defaultMaxConcurrency = 4
+	shell                 = "/bin/sh"
+	// the output kept of a command, a runaway script must not fill the memory
+	maxOutput = 64 << 10
+	// the tail of stderr put into the error
+	maxStderr = 512
+)
+
+// Command runs a shell command on every scrape, the query of a metric is the command line,
+// its stdout is either a single number or a json array of time and value samples,
+// e.g. [{"time": 1700000000, "value": 3}] or [[1700000000, 3]], the time in unix seconds or RFC3339
+type Command struct {
+	collector.CollectorBase
+	timeout time.Duration
+	// caps the commands of the collector running at once
+	slots chan struct{}
+	// guards MetricQL and ServerAddress
+	mu sync.RWMutex
+}
+
+func New(timeout time.Duration, maxConcurrency int) collector.Collector {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	if maxConcurrency <= 0 {
+		maxConcurrency = defaultMaxConcurrency
+	}
+	res := &Command{
+		timeout: timeout,
+		slots:   make(chan struct{}, maxConcurrency),
+	}
+	res.MetricQL = make(map[basetype.Metric]string)
+	return res
+}
+
+// SetServerAddress only records the address, the commands reach their sources themselves
+func (c *Command) SetServerAddress(url string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ServerAddress = url
+	return nil
+}
+
+func (c *Command) ListMetricTypes() []basetype.Metric {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	result := make([]basetype.Metric, 0, len(c.MetricQL))
+	for m := range c.MetricQL {
+		result = append(result, m)
+	}
+	return result
+}
+
+func (c *Command) AddCustomMetrics(metric basetype.Metric, query string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.MetricQL[metric] = query
+}
+
+func (c *Command) CreateWorker(metric basetype.Metric) (collector.MetricCollector, error) {
+	c.mu.RLock()
+	query, ok := c.MetricQL[metric]
+	c.mu.RUnlock()
+	if !ok {
+		return nil, errors.New("undefined metric type")
+	}
+	if strings.TrimSpace(query) == "" {
+		return nil, errors.New("empty command")
+	}
+	return &worker{
+		MetricType: collector.MetricType{
+			Name: metric.Name,
+			Unit: metric.Unit,
+		},
+		key:     metric.NoModelKey(),
+		command: query,
+		exec:    c,
+		now:     time.Now,
+	}, nil
+}
+
+// run waits for a free slot and then runs the command, each within the timeout
+func (c *Command) run(command string) ([]byte, error) {
+	wait := time.NewTimer(c.timeout)
+	select {
+	case c.slots <- struct{}{}:
+		wait.Stop()
+	case <-wait.C:
+		return nil, fmt.Errorf("no free slot within %s, %d commands still running", c.timeout, cap(c.slots))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	stdout, stderr := &limitedBuffer{}, &limitedBuffer{}
+	cmd := exec.Command(shell, "-c", command)
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		<-c.slots
+		return nil, err
+	}
+	done := make(chan error, 1)
+	go func() {
+		// the slot is held until the command is really gone, its children are killed along with it
+		// since they may keep its output open
+		done <- cmd.Wait()
+		<-c.slots
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", err, stderr.tail())
+		}
+		return stdout.Bytes(), nil
+	case <-ctx.Done():
+		_ = killProcessGroup(cmd)
+		return nil, fmt.Errorf("timed out after %s: %s", c.timeout, stderr.tail())
+	}
+}
+
+// limitedBuffer drops what is written beyond maxOutput
+type limitedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if left := maxOutput - b.buf.Len(); left > 0 {
+		if len(p) > left {
+			b.buf.Write(p[:left])
+		} else {
+			b.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.buf.Bytes()...)
+}
+
+func (b *limitedBuffer) tail() string {
+	s := strings.TrimSpace(string(b.Bytes()))
+	if len(s) > maxStderr {
+		s = "..." + s[len(s)-maxStderr:]
+	}
+	if s == "" {
+		return "no stderr"
+	}
+	return s
+}
+
+type worker struct {
+	collector.MetricType
+	collector.Buffer
+	key     string
+	command string
+	exec    *Command
+	now     func() time.Time
+	// the samples of array outputs not after it were collected already
+	lastTimeStamp time.Time
+}
+
+func (w *worker) Collect() error {
+	out, err := w.exec.run(w.command)
+	if err != nil {
+		return fmt.Errorf("command [%s]: %w", w.command, err)
+	}
+	metrics, series, err := Parse(out, w.now())
+	if err != nil {
+		return fmt.Errorf("command [%s]: %w", w.command, err)
+	}
+	if series {
+		fresh := metrics[:0]
+		for _, m := range metrics {
+			if m.TimeStamp.After(w.lastTimeStamp) {
+				fresh = append(fresh, m)
+			}
+		}
+		metrics = fresh
+		if len(metrics) != 0 {
+			w.lastTimeStamp = metrics[len(metrics)-1].TimeStamp
+		}
+	}
+	if len(metrics) == 0 {
+		return errs.EMPTY_RESULT
+	}
+	w.Append(metrics...)
+	return nil
+}
+
+type sample struct {
+	Time  json.RawMessage `json:"time"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Parse reads the output of a command, a single number is stamped with now,
+// a json array yields its samples sorted by time and tells so by series
+func Parse(out []byte, now time.Time) ([]collector.Metric, bool, error) {
+	out = bytes.TrimSpace(out)
+	if len(out) == 0 {
+		return nil, false, nil
+	}
+	if out[0] != '[' {
+		value, err := strconv.ParseFloat(string(out), 64)
+		if err != nil {
+			return nil, false, fmt.Errorf("output [%s] is not a number", out)
+		}
+		return []collector.Metric{{Value: value, TimeStamp: now}}, false, nil
+	}
+	var raw []json.RawMessage
+	if err := json.Unmarshal(out, &raw); err != nil {
+		return nil, true, fmt.Errorf("invalid json output: %w", err)
+	}
+	res := make([]collector.Metric, 0, len(raw))
+	for i, r := range raw {
+		var s sample
+		if pair := []json.RawMessage{}; json.Unmarshal(r, &pair) == nil {
+			if len(pair) != 2 {
+				return nil, true, fmt.Errorf("sample %d has %d elements, expect time and value", i, len(pair))
+			}
+			s.Time, s.Value = pair[0], pair[1]
+		} else if err := json.Unmarshal(r, &s); err != nil {
+			return nil, true, fmt.Errorf("sample %d: %w", i, err)
+		}
+		ts, err := parseTime(s.Time)
+		if err != nil {
+			return nil, true, fmt.Errorf("sample %d: %w", i, err)
+		}
+		value, err := parseValue(s.Value)
+		if err != nil {
+			return nil, true, fmt.Errorf("sample %d: %w", i, err)
+		}
+		res = append(res, collector.Metric{Value: value, TimeStamp: ts})
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].TimeStamp.Before(res[j].TimeStamp)
+	})
+	return res, true, nil
+}
+
+func parseValue(raw json.RawMessage) (float64, error) {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return strconv.ParseFloat(strings.TrimSpace(s), 64)
+	}
+	var v float64
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return 0, fmt.Errorf("value %s is not a number", raw)
+	}
+	return v, nil
+}
+
+func parseTime(raw json.RawMessage) (time.Time, error) {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		ts, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time [%s]", s)
+		}
+		return ts, nil
+	}
+	var seconds float64
+	if err := json.Unmarshal(raw, &seconds); err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %s", raw)
+	}
+	sec, frac := math.Modf(seconds)
+	return time.Unix(int64(sec), int64(frac*1e9)), nil
+}
+
+func (w *worker) NoModelKey() string {
+	return w.key
+}
@@ -0,0 +1,170 @@
+package exec_collector
+
+import (
+	"errors"
+	"fmt"
+	"github.com/LL-res/AOM/common/basetype"
+	"github.com/LL-res/AOM/common/errs"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		out     string
+		want    []float64
+		times   []int64
+		series  bool
+		wantErr bool
+	}{
+		{out: " 42\n", want: []float64{42}, times: []int64{now.Unix()}},
+		{out: "", want: nil},
+		{out: `[{"time": 20, "value": 2}, {"time": "1970-01-01T00:00:10Z", "value": "1.5"}]`, want: []float64{1.5, 2}, times: []int64{10, 20}, series: true},
+		{out: `[[30, 3], [10, 1]]`, want: []float64{1, 3}, times: []int64{10, 30}, series: true},
+		{out: "[]", series: true},
+		{out: "ok", wantErr: true},
+		{out: `[[10]]`, series: true, wantErr: true},
+		{out: `[{"time": "yesterday", "value": 1}]`, series: true, wantErr: true},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			got, series, err := Parse([]byte(tt.out), now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if series != tt.series {
+				t.Errorf("got series %v, want %v", series, tt.series)
+			}
+			if err != nil {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for j := range got {
+				if got[j].Value != tt.want[j] || got[j].TimeStamp.Unix() != tt.times[j] {
+					t.Errorf("got %v, want %v at %v", got, tt.want, tt.times)
+				}
+			}
+		})
+	}
+}
+
+func TestCollect(t *testing.T) {
+	c := New(time.Second, 2)
+	newWorker := func(command string) *worker {
+		metric := basetype.Metric{Name: command, Query: command}
+		c.AddCustomMetrics(metric, command)
+		w, err := c.CreateWorker(metric)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return w.(*worker)
+	}
+	tests := []struct {
+		command string
+		want    float64
+		wantErr string
+	}{
+		{command: "echo 7", want: 7},
+		{command: "echo '[[1700000000, 3]]'", want: 3},
+		{command: "true", wantErr: errs.EMPTY_RESULT.Error()},
+		{command: "echo no such source >&2; exit 3", wantErr: "no such source"},
+		{command: "echo NaN-ish", wantErr: "not a number"},
+		{command: "sleep 5", wantErr: "timed out"},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			w := newWorker(tt.command)
+			err := w.Collect()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got, ok := w.Latest(); !ok || got.Value != tt.want {
+					t.Errorf("got %v, want %v", got.Value, tt.want)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+	// samples already collected are not taken again
+	w := newWorker("echo '[[10, 1], [20, 2]]'")
+	if err := w.Collect(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Collect(); !errors.Is(err, errs.EMPTY_RESULT) {
+		t.Errorf("got error %v, want %v", err, errs.EMPTY_RESULT)
+	}
+	if got := w.Send(); len(got) != 2 {
+		t.Errorf("got %v, want 2 samples", got)
+	}
+}
+
+func TestConcurrencyCap(t *testing.T) {
+	c := New(500*time.Millisecond, 1)
+	metric := basetype.Metric{Name: "slow", Query: "sleep 0.3; echo 1"}
+	c.AddCustomMetrics(metric, metric.Query)
+	var errors []error
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for i := 0; i < 3; i++ {
+		w, err := c.CreateWorker(metric)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := w.Collect()
+			mu.Lock()
+			defer mu.Unlock()
+			errors = append(errors, err)
+		}()
+	}
+	wg.Wait()
+	failed := 0
+	for _, err := range errors {
+		if err != nil {
+			if !strings.Contains(err.Error(), "no free slot") {
+				t.Errorf("unexpected error %v", err)
+			}
+			failed++
+		}
+	}
+	// one runs, the second waits for it, the third runs out of time waiting
+	if failed != 1 {
+		t.Errorf("got %d failures, want 1", failed)
+	}
+}
+
+func TestTimeoutKillsChildren(t *testing.T) {
+	c := New(200*time.Millisecond, 1)
+	// the background child keeps stdout open after the shell is killed
+	hang := basetype.Metric{Name: "hang", Query: "sleep 5 & sleep 5"}
+	quick := basetype.Metric{Name: "quick", Query: "echo 1"}
+	for _, m := range []basetype.Metric{hang, quick} {
+		c.AddCustomMetrics(m, m.Query)
+	}
+	w, err := c.CreateWorker(hang)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Collect(); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("got error %v, want a timeout", err)
+	}
+	w, err = c.CreateWorker(quick)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the slot is free once the children are gone as well
+	if err := w.Collect(); err != nil {
+		t.Errorf("slot still held by the killed command: %v", err)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package exec_collector
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts the command in a group of its own, so that its children can be killed along with it
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup kills the command and the children it started, which may keep its output open otherwise
+func killProcessGroup(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
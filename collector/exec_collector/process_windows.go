@@ -0,0 +1,10 @@
+package exec_collector
+
+import "os/exec"
+
+// setProcessGroup is a no-op, the children of a command are not tracked on windows
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
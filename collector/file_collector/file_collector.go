@@ -0,0 +1,273 @@
+package file_collector
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/LL-res/AOM/collector"
+	"github.com/LL-res/AOM/common/basetype"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the shortest pass over a looped trace
+const minPass = time.Millisecond
+
+// FileReplay replays recorded traces, the query of a metric is the path of a csv or jsonl file
+// holding timestamp and value columns
+type FileReplay struct {
+	collector.CollectorBase
+	// how many times faster than wall clock the trace is replayed
+	speed int
+	// start from the beginning again once the trace is exhausted
+	loop bool
+	// guards MetricQL
+	mu sync.RWMutex
+}
+
+func New(speed int, loop bool) collector.Collector {
+	if speed <= 0 {
+		speed = 1
+	}
+	res := &FileReplay{
+		speed: speed,
+		loop:  loop,
+	}
+	res.MetricQL = make(map[basetype.Metric]string)
+	return res
+}
+
+// SetServerAddress is a no-op, the traces are read from the paths in the metric queries
+func (f *FileReplay) SetServerAddress(url string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ServerAddress = url
+	return nil
+}
+
+func (f *FileReplay) ListMetricTypes() []basetype.Metric {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	result := make([]basetype.Metric, 0, len(f.MetricQL))
+	for m := range f.MetricQL {
+		result = append(result, m)
+	}
+	return result
+}
+
+func (f *FileReplay) AddCustomMetrics(metric basetype.Metric, query string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.MetricQL[metric] = query
+}
+
+func (f *FileReplay) CreateWorker(metric basetype.Metric) (collector.MetricCollector, error) {
+	f.mu.RLock()
+	path, ok := f.MetricQL[metric]
+	f.mu.RUnlock()
+	if !ok {
+		return nil, errors.New("undefined metric type")
+	}
+	trace, err := LoadTrace(path)
+	if err != nil {
+		return nil, err
+	}
+	return &worker{
+		MetricType: collector.MetricType{
+			Name: metric.Name,
+			Unit: metric.Unit,
+		},
+		key:   metric.NoModelKey(),
+		path:  path,
+		trace: trace,
+		speed: f.speed,
+		loop:  f.loop,
+		now:   time.Now,
+	}, nil
+}
+
+// LoadTrace reads a trace sorted by time stamp, the format is chosen by the file extension
+func LoadTrace(path string) ([]collector.Metric, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var trace []collector.Metric
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		trace, err = readCSV(file)
+	case ".jsonl", ".ndjson":
+		trace, err = readJSONL(file)
+	default:
+		return nil, fmt.Errorf("unsupported trace file [%s], expect .csv or .jsonl", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read trace [%s] failed: %w", path, err)
+	}
+	if len(trace) == 0 {
+		return nil, fmt.Errorf("trace [%s] is empty", path)
+	}
+	sort.SliceStable(trace, func(i, j int) bool {
+		return trace[i].TimeStamp.Before(trace[j].TimeStamp)
+	})
+	return trace, nil
+}
+
+// readCSV expects timestamp,value rows, a header naming the columns is optional
+func readCSV(r io.Reader) ([]collector.Metric, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	tsCol, valCol := 0, 1
+	res := make([]collector.Metric, 0, len(records))
+	for i, record := range records {
+		if i == 0 {
+			if idx := indexOf(record, "timestamp", "time"); idx >= 0 {
+				tsCol = idx
+				if idx = indexOf(record, "value"); idx >= 0 {
+					valCol = idx
+				}
+				continue
+			}
+		}
+		if len(record) <= tsCol || len(record) <= valCol {
+			return nil, fmt.Errorf("line %d: expect timestamp and value columns", i+1)
+		}
+		ts, err := parseTimeStamp(record[tsCol])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		val, err := strconv.ParseFloat(strings.TrimSpace(record[valCol]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		res = append(res, collector.Metric{Value: val, TimeStamp: ts})
+	}
+	return res, nil
+}
+
+func indexOf(record []string, names ...string) int {
+	for i, field := range record {
+		for _, name := range names {
+			if strings.EqualFold(strings.TrimSpace(field), name) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// readJSONL expects one {"timestamp": ..., "value": ...} object per line
+func readJSONL(r io.Reader) ([]collector.Metric, error) {
+	res := make([]collector.Metric, 0)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		point := struct {
+			TimeStamp json.RawMessage `json:"timestamp"`
+			Value     *float64        `json:"value"`
+		}{}
+		if err := json.Unmarshal([]byte(text), &point); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if point.TimeStamp == nil || point.Value == nil {
+			return nil, fmt.Errorf("line %d: expect timestamp and value fields", line)
+		}
+		ts, err := parseTimeStamp(strings.Trim(string(point.TimeStamp), `"`))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		res = append(res, collector.Metric{Value: *point.Value, TimeStamp: ts})
+	}
+	return res, scanner.Err()
+}
+
+// parseTimeStamp accepts RFC3339 or unix seconds
+func parseTimeStamp(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if seconds, err := strconv.ParseFloat(s, 64); err == nil {
+		sec, frac := math.Modf(seconds)
+		return time.Unix(int64(sec), int64(frac*1e9)), nil
+	}
+	ts, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp [%s], expect RFC3339 or unix seconds", s)
+	}
+	return ts, nil
+}
+
+type worker struct {
+	collector.MetricType
+	collector.Buffer
+	key   string
+	path  string
+	trace []collector.Metric
+	speed int
+	loop  bool
+	now   func() time.Time
+	// wall clock time the current pass over the trace started
+	start time.Time
+	// index of the next point to replay
+	next int
+}
+
+// Collect appends every point of the trace whose replay time has come,
+// the time stamps are moved to the replay time
+func (w *worker) Collect() error {
+	now := w.now()
+	if w.start.IsZero() {
+		w.start = now
+	}
+	first := w.trace[0].TimeStamp
+	for {
+		if w.next == len(w.trace) {
+			if !w.loop {
+				return fmt.Errorf("trace [%s] replayed to the end", w.path)
+			}
+			// the next pass starts one sample interval after the last point of this one
+			w.start = w.start.Add(w.passDuration())
+			w.next = 0
+		}
+		replayAt := w.start.Add(w.trace[w.next].TimeStamp.Sub(first) / time.Duration(w.speed))
+		if replayAt.After(now) {
+			return nil
+		}
+		w.Append(collector.Metric{
+			Value:     w.trace[w.next].Value,
+			TimeStamp: replayAt,
+		})
+		w.next++
+	}
+}
+
+// passDuration is at least minPass, so that a pass always ends after the one before,
+// e.g. when all the points of the trace share a time stamp
+func (w *worker) passDuration() time.Duration {
+	span := w.trace[len(w.trace)-1].TimeStamp.Sub(w.trace[0].TimeStamp)
+	step := time.Second
+	if len(w.trace) > 1 && span > 0 {
+		step = span / time.Duration(len(w.trace)-1)
+	}
+	if pass := (span + step) / time.Duration(w.speed); pass >= minPass {
+		return pass
+	}
+	return minPass
+}
+
+func (w *worker) NoModelKey() string {
+	return w.key
+}
@@ -0,0 +1,120 @@
+package file_collector
+
+import (
+	"fmt"
+	"github.com/LL-res/AOM/common/basetype"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTrace(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadTrace(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []float64
+		wantErr bool
+	}{
+		{name: "header.csv", content: "value,timestamp\n2,1700000010\n1,1700000000\n", want: []float64{1, 2}},
+		{name: "plain.csv", content: "2023-11-14T22:13:20Z,5\n2023-11-14T22:13:30Z,6\n", want: []float64{5, 6}},
+		{name: "trace.jsonl", content: `{"timestamp":1700000000,"value":3}` + "\n\n" + `{"timestamp":"2023-11-14T22:13:30Z","value":4}` + "\n", want: []float64{3, 4}},
+		{name: "bad.csv", content: "1700000000,abc\n", wantErr: true},
+		{name: "missing.jsonl", content: `{"timestamp":1700000000}` + "\n", wantErr: true},
+		{name: "trace.txt", content: "1700000000,1\n", wantErr: true},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			trace, err := LoadTrace(writeTrace(t, tt.name, tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if len(trace) != len(tt.want) {
+				t.Fatalf("got %v, want %v", trace, tt.want)
+			}
+			for j := range trace {
+				if trace[j].Value != tt.want[j] {
+					t.Errorf("got %v, want %v", trace, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestReplay(t *testing.T) {
+	path := writeTrace(t, "trace.csv", "timestamp,value\n0,1\n10,2\n20,3\n")
+	tests := []struct {
+		speed int
+		loop  bool
+		// wall clock offsets Collect is called at
+		at   []time.Duration
+		want []int
+		// whether the last Collect reports the end of the trace
+		wantEnd bool
+	}{
+		{speed: 1, at: []time.Duration{0, 5 * time.Second, 10 * time.Second, 25 * time.Second}, want: []int{1, 1, 2, 3}, wantEnd: true},
+		{speed: 10, at: []time.Duration{0, time.Second, 2 * time.Second}, want: []int{1, 2, 3}, wantEnd: true},
+		{speed: 10, loop: true, at: []time.Duration{0, 3 * time.Second, 4 * time.Second}, want: []int{1, 4, 5}},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			metric := basetype.Metric{Name: "trace", Query: path}
+			c := New(tt.speed, tt.loop)
+			c.AddCustomMetrics(metric, metric.Query)
+			mc, err := c.CreateWorker(metric)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := mc.(*worker)
+			start := time.Now()
+			for j, offset := range tt.at {
+				w.now = func() time.Time { return start.Add(offset) }
+				err = w.Collect()
+				if w.DataCap() != tt.want[j] {
+					t.Errorf("after collect %d got %d points, want %d", j, w.DataCap(), tt.want[j])
+				}
+			}
+			if (err != nil) != tt.wantEnd {
+				t.Errorf("unexpected end state %v", err)
+			}
+			data := w.Send()
+			if len(data) > 1 && !data[1].TimeStamp.Equal(start.Add(10*time.Second/time.Duration(tt.speed))) {
+				t.Errorf("time stamp not moved to replay time: %v", data[1].TimeStamp)
+			}
+		})
+	}
+}
+
+func TestReplayEqualTimeStamps(t *testing.T) {
+	path := writeTrace(t, "trace.csv", "timestamp,value\n0,1\n0,2\n0,3\n")
+	metric := basetype.Metric{Name: "trace", Query: path}
+	c := New(1, true)
+	c.AddCustomMetrics(metric, metric.Query)
+	mc, err := c.CreateWorker(metric)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := mc.(*worker)
+	start := time.Now()
+	// a pass of points sharing a time stamp lasts a second, as the one of a single point
+	for j, tt := range []struct {
+		offset time.Duration
+		want   int
+	}{{0, 3}, {2 * time.Second, 9}} {
+		w.now = func() time.Time { return start.Add(tt.offset) }
+		if err := w.Collect(); err != nil {
+			t.Fatal(err)
+		}
+		if w.DataCap() != tt.want {
+			t.Errorf("after collect %d got %d points, want %d", j, w.DataCap(), tt.want)
+		}
+	}
+}
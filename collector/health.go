@@ -0,0 +1,88 @@
+package collector
+
+import (
+	"errors"
+	"github.com/LL-res/AOM/common/errs"
+	"sync"
+	"time"
+)
+
+// healthWindow is how many of the latest collects the error rate is computed over
+const healthWindow = 20
+
+// Health tracks the outcome of the collects of one worker
+type Health struct {
+	mu      sync.Mutex
+	created time.Time
+	// ring of the latest outcomes, true for a failure
+	outcomes [healthWindow]bool
+	attempts int
+	snapshot HealthSnapshot
+}
+
+type HealthSnapshot struct {
+	LastSuccess time.Time
+	// the time stamp of the latest sample collected
+	LastSample          time.Time
+	ConsecutiveFailures int
+	// consecutive collects that succeeded without any sample
+	ConsecutiveEmpty int
+	LastError        string
+	// failures of the latest collects in percent, empty results do not count as failures
+	ErrorRate int
+}
+
+func NewHealth(now time.Time) *Health {
+	return &Health{created: now}
+}
+
+// Observe records the result of a Collect, latest is the newest sample of the worker if it has any
+func (h *Health) Observe(now time.Time, err error, latest Metric, hasLatest bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	failed := err != nil && !errors.Is(err, errs.EMPTY_RESULT)
+	h.outcomes[h.attempts%healthWindow] = failed
+	h.attempts++
+	switch {
+	case failed:
+		h.snapshot.ConsecutiveFailures++
+		h.snapshot.LastError = err.Error()
+	case err != nil:
+		h.snapshot.ConsecutiveFailures = 0
+		h.snapshot.ConsecutiveEmpty++
+		h.snapshot.LastSuccess = now
+	default:
+		h.snapshot.ConsecutiveFailures = 0
+		h.snapshot.ConsecutiveEmpty = 0
+		h.snapshot.LastSuccess = now
+	}
+	if hasLatest && latest.TimeStamp.After(h.snapshot.LastSample) {
+		h.snapshot.LastSample = latest.TimeStamp
+	}
+	n, failures := h.attempts, 0
+	if n > healthWindow {
+		n = healthWindow
+	}
+	for _, f := range h.outcomes[:n] {
+		if f {
+			failures++
+		}
+	}
+	h.snapshot.ErrorRate = failures * 100 / n
+}
+
+func (h *Health) Snapshot() HealthSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.snapshot
+}
+
+// SampleAge is how old the latest sample is, or how long the worker has gone without any sample
+func (h *Health) SampleAge(now time.Time) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.snapshot.LastSample.IsZero() {
+		return now.Sub(h.created)
+	}
+	return now.Sub(h.snapshot.LastSample)
+}
@@ -0,0 +1,68 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"github.com/LL-res/AOM/common/errs"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	start := time.Now()
+	failed := errors.New("connection refused")
+	tests := []struct {
+		results []error
+		want    HealthSnapshot
+	}{
+		{
+			results: []error{nil, failed, failed},
+			want:    HealthSnapshot{ConsecutiveFailures: 2, LastError: "connection refused", ErrorRate: 66},
+		},
+		{
+			results: []error{failed, nil, errs.EMPTY_RESULT, errs.EMPTY_RESULT},
+			want:    HealthSnapshot{ConsecutiveEmpty: 2, LastError: "connection refused", ErrorRate: 25},
+		},
+		{
+			results: []error{errs.EMPTY_RESULT, nil},
+			want:    HealthSnapshot{},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			h := NewHealth(start)
+			for _, err := range tt.results {
+				h.Observe(start, err, Metric{}, false)
+			}
+			got := h.Snapshot()
+			got.LastSuccess = time.Time{}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHealthSampleAge(t *testing.T) {
+	start := time.Now()
+	h := NewHealth(start)
+	if age := h.SampleAge(start.Add(time.Minute)); age != time.Minute {
+		t.Errorf("got age %s without samples, want 1m", age)
+	}
+	h.Observe(start, nil, Metric{Value: 1, TimeStamp: start.Add(30 * time.Second)}, true)
+	// an older sample does not move the age back
+	h.Observe(start, nil, Metric{Value: 1, TimeStamp: start}, true)
+	if age := h.SampleAge(start.Add(time.Minute)); age != 30*time.Second {
+		t.Errorf("got age %s, want 30s", age)
+	}
+	// the error rate only looks at the latest collects
+	for i := 0; i < healthWindow; i++ {
+		h.Observe(start, errors.New("timeout"), Metric{}, false)
+	}
+	for i := 0; i < healthWindow/2; i++ {
+		h.Observe(start, nil, Metric{}, false)
+	}
+	if rate := h.Snapshot().ErrorRate; rate != 50 {
+		t.Errorf("got error rate %d, want 50", rate)
+	}
+}
@@ -0,0 +1,179 @@
+package collector
+
+import (
+	"fmt"
+	"github.com/LL-res/AOM/common/errs"
+	"sort"
+	"time"
+)
+
+// the resolutions the history of a worker is kept at
+const (
+	ResolutionRaw        = time.Duration(0)
+	ResolutionMinute     = time.Minute
+	ResolutionTenMinutes = 10 * time.Minute
+)
+
+// Resolutions lists every resolution the history is kept at
+var Resolutions = []time.Duration{ResolutionRaw, ResolutionMinute, ResolutionTenMinutes}
+
+// Retention decides how long each tier of the history is kept
+type Retention struct {
+	Raw        time.Duration
+	Minute     time.Duration
+	TenMinutes time.Duration
+}
+
+// HistoryRetention is used by the workers created after it is set, days of 1m and weeks of 10m rollups by default
+var HistoryRetention = Retention{
+	Raw:        6 * time.Hour,
+	Minute:     3 * 24 * time.Hour,
+	TenMinutes: 14 * 24 * time.Hour,
+}
+
+// ValidResolution tells whether the history is kept at resolution
+func ValidResolution(resolution time.Duration) error {
+	switch resolution {
+	case ResolutionRaw, ResolutionMinute, ResolutionTenMinutes:
+		return nil
+	default:
+		return fmt.Errorf("unsupported resolution %s, expect one of 0, %s and %s", resolution, ResolutionMinute, ResolutionTenMinutes)
+	}
+}
+
+// tier keeps the points of one resolution, the points of a rollup are the means of their buckets
+// stamped with the start of the bucket
+type tier struct {
+	resolution time.Duration
+	retention  time.Duration
+	points     []Metric
+	// the bucket being filled, it joins points once a later bucket starts
+	bucket time.Time
+	sum    float64
+	count  int
+	// the points pruned since the tier was last checkpointed in full
+	pruned int
+}
+
+// add returns the point joining points, if any
+func (t *tier) add(m Metric) (Metric, bool) {
+	if t.resolution == ResolutionRaw {
+		t.points = append(t.points, m)
+		t.prune(m.TimeStamp)
+		return m, true
+	}
+	bucket := m.TimeStamp.Truncate(t.resolution)
+	if len(t.points) != 0 && !bucket.After(t.points[len(t.points)-1].TimeStamp) {
+		// the bucket was closed already, e.g. before a restart
+		return Metric{}, false
+	}
+	var closed Metric
+	var ok bool
+	if t.count != 0 && bucket.After(t.bucket) {
+		closed, ok = Metric{Value: t.sum / float64(t.count), TimeStamp: t.bucket}, true
+		t.points = append(t.points, closed)
+		t.sum, t.count = 0, 0
+		t.prune(m.TimeStamp)
+	}
+	if t.count != 0 && bucket.Before(t.bucket) {
+		// the bucket was closed already
+		return closed, ok
+	}
+	t.bucket = bucket
+	t.sum += m.Value
+	t.count++
+	return closed, ok
+}
+
+func (t *tier) prune(now time.Time) {
+	if t.retention <= 0 {
+		return
+	}
+	oldest := now.Add(-t.retention)
+	cut := sort.Search(len(t.points), func(i int) bool {
+		return !t.points[i].TimeStamp.Before(oldest)
+	})
+	t.points = t.points[cut:]
+	t.pruned += cut
+}
+
+// History keeps the points of a worker at every resolution, it is not safe for concurrent use
+type History struct {
+	tiers []*tier
+	last  time.Time
+}
+
+func NewHistory(retention Retention) *History {
+	return &History{
+		tiers: []*tier{
+			{resolution: ResolutionRaw, retention: retention.Raw},
+			{resolution: ResolutionMinute, retention: retention.Minute},
+			{resolution: ResolutionTenMinutes, retention: retention.TenMinutes},
+		},
+	}
+}
+
+// Add feeds every tier, the points not after the last one added are dropped
+func (h *History) Add(metrics ...Metric) {
+	h.add(metrics, nil)
+}
+
+// add is Add calling added with every point joining a tier
+func (h *History) add(metrics []Metric, added func(resolution time.Duration, m Metric)) {
+	for _, m := range metrics {
+		if !m.TimeStamp.After(h.last) {
+			continue
+		}
+		h.last = m.TimeStamp
+		for _, t := range h.tiers {
+			if p, ok := t.add(m); ok && added != nil {
+				added(t.resolution, p)
+			}
+		}
+	}
+}
+
+// restore puts back the points a rollup tier held, the raw points are added afterwards to refill the open buckets
+func (h *History) restore(resolution time.Duration, points []Metric) {
+	t := h.tier(resolution)
+	if t == nil || t.resolution == ResolutionRaw {
+		return
+	}
+	for _, p := range points {
+		if len(t.points) == 0 || p.TimeStamp.After(t.points[len(t.points)-1].TimeStamp) {
+			t.points = append(t.points, p)
+		}
+	}
+	if len(t.points) != 0 {
+		t.prune(t.points[len(t.points)-1].TimeStamp)
+	}
+}
+
+// tier returns the tier kept at resolution
+func (h *History) tier(resolution time.Duration) *tier {
+	for _, t := range h.tiers {
+		if t.resolution == resolution {
+			return t
+		}
+	}
+	return nil
+}
+
+// Window returns the latest n points at resolution, only complete buckets are returned for the rollups
+func (h *History) Window(resolution time.Duration, n int) ([]Metric, error) {
+	if err := ValidResolution(resolution); err != nil {
+		return nil, err
+	}
+	for _, t := range h.tiers {
+		if t.resolution != resolution {
+			continue
+		}
+		if len(t.points) < n {
+			return nil, errs.NO_SUFFICENT_DATA
+		}
+		res := make([]Metric, n)
+		copy(res, t.points[len(t.points)-n:])
+		return res, nil
+	}
+	return nil, errs.NO_SUFFICENT_DATA
+}
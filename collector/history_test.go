@@ -0,0 +1,71 @@
+package collector
+
+import (
+	"fmt"
+	"github.com/LL-res/AOM/common/errs"
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	start := time.Date(2023, 11, 14, 0, 0, 0, 0, time.UTC)
+	h := NewHistory(Retention{Raw: 5 * time.Minute, Minute: time.Hour})
+	// 15s scrape interval for 25 minutes, the value is the minute it was collected at
+	for i := 0; i < 100; i++ {
+		h.Add(Metric{Value: float64(i / 4), TimeStamp: start.Add(time.Duration(i) * 15 * time.Second)})
+	}
+	// out of order points are dropped
+	h.Add(Metric{Value: 1000, TimeStamp: start})
+	tests := []struct {
+		resolution time.Duration
+		n          int
+		want       []float64
+		wantErr    error
+	}{
+		{resolution: ResolutionRaw, n: 3, want: []float64{24, 24, 24}},
+		// the raw points are kept for 5 minutes only
+		{resolution: ResolutionRaw, n: 22, wantErr: errs.NO_SUFFICENT_DATA},
+		// the bucket of minute 24 is still open
+		{resolution: ResolutionMinute, n: 3, want: []float64{21, 22, 23}},
+		{resolution: ResolutionMinute, n: 24, want: []float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23}},
+		{resolution: ResolutionTenMinutes, n: 2, want: []float64{4.5, 14.5}},
+		{resolution: ResolutionTenMinutes, n: 3, wantErr: errs.NO_SUFFICENT_DATA},
+		{resolution: 5 * time.Minute, n: 1, wantErr: fmt.Errorf("unsupported")},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			got, err := h.Window(tt.resolution, tt.n)
+			if (err != nil) != (tt.wantErr != nil) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == errs.NO_SUFFICENT_DATA && err != errs.NO_SUFFICENT_DATA {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+			values := make([]float64, 0, len(got))
+			for _, m := range got {
+				values = append(values, m.Value)
+			}
+			if err == nil && fmt.Sprint(values) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", values, tt.want)
+			}
+		})
+	}
+	got, _ := h.Window(ResolutionMinute, 1)
+	if want := start.Add(23 * time.Minute); !got[0].TimeStamp.Equal(want) {
+		t.Errorf("rollup stamped %s, want the start of its bucket %s", got[0].TimeStamp, want)
+	}
+}
+
+func TestBufferWindow(t *testing.T) {
+	b := &Buffer{}
+	now := time.Now()
+	b.Append(Metric{Value: 1, TimeStamp: now}, Metric{Value: 2, TimeStamp: now.Add(time.Second)})
+	b.Send()
+	got, err := b.Window(ResolutionRaw, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[1].Value != 2 {
+		t.Errorf("sent metrics not kept in the history, got %v", got)
+	}
+}
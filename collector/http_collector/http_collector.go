@@ -0,0 +1,244 @@
+package http_collector
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/LL-res/AOM/collector"
+	"github.com/LL-res/AOM/common/basetype"
+	"github.com/LL-res/AOM/common/errs"
+	"io"
+	"k8s.io/client-go/util/jsonpath"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultTimeout = 10 * time.Second
+
+// Endpoint polls json http endpoints, the query of a metric is a jsonpath extracting a single number,
+// optionally led by a path or url resolved against the server address,
+// e.g. {.queues[?(@.name=="orders")].depth} or /api/stats {.inFlight}
+type Endpoint struct {
+	collector.CollectorBase
+	method string
+	body   string
+	client *http.Client
+	// guards MetricQL, ServerAddress and client
+	mu sync.RWMutex
+}
+
+func New(method, body string, timeout time.Duration) collector.Collector {
+	if method == "" {
+		method = http.MethodGet
+	}
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	res := &Endpoint{
+		method: method,
+		body:   body,
+		client: &http.Client{Timeout: timeout},
+	}
+	res.MetricQL = make(map[basetype.Metric]string)
+	return res
+}
+
+func (e *Endpoint) SetServerAddress(address string) error {
+	if _, err := url.Parse(address); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.ServerAddress = address
+	return nil
+}
+
+func (e *Endpoint) SetRoundTripper(rt http.RoundTripper) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// a new client so that the requests in flight keep the old one
+	e.client = &http.Client{Transport: rt, Timeout: e.client.Timeout}
+	return nil
+}
+
+func (e *Endpoint) getClient() (*http.Client, string) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.client, e.ServerAddress
+}
+
+func (e *Endpoint) ListMetricTypes() []basetype.Metric {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	result := make([]basetype.Metric, 0, len(e.MetricQL))
+	for m := range e.MetricQL {
+		result = append(result, m)
+	}
+	return result
+}
+
+func (e *Endpoint) AddCustomMetrics(metric basetype.Metric, query string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.MetricQL[metric] = query
+}
+
+func (e *Endpoint) CreateWorker(metric basetype.Metric) (collector.MetricCollector, error) {
+	e.mu.RLock()
+	query, ok := e.MetricQL[metric]
+	e.mu.RUnlock()
+	if !ok {
+		return nil, errors.New("undefined metric type")
+	}
+	path, expr := ParseQuery(query)
+	// a missing key is an empty result rather than an error
+	jp := jsonpath.New(metric.Name).AllowMissingKeys(true)
+	if err := jp.Parse(expr); err != nil {
+		return nil, fmt.Errorf("invalid jsonpath [%s]: %w", expr, err)
+	}
+	return &worker{
+		MetricType: collector.MetricType{
+			Name: metric.Name,
+			Unit: metric.Unit,
+		},
+		key:      metric.NoModelKey(),
+		path:     path,
+		jsonPath: jp,
+		endpoint: e,
+	}, nil
+}
+
+// ParseQuery splits a query into the optional path and the jsonpath, the braces of the jsonpath may be left out
+func ParseQuery(query string) (string, string) {
+	query = strings.TrimSpace(query)
+	path := ""
+	if !strings.HasPrefix(query, "{") && !strings.HasPrefix(query, "$") && !strings.HasPrefix(query, ".") {
+		if i := strings.IndexAny(query, " \t"); i > 0 {
+			path, query = query[:i], strings.TrimSpace(query[i:])
+		}
+	}
+	if !strings.HasPrefix(query, "{") {
+		query = "{" + query + "}"
+	}
+	return path, query
+}
+
+type worker struct {
+	collector.MetricType
+	collector.Buffer
+	key      string
+	path     string
+	jsonPath *jsonpath.JSONPath
+	endpoint *Endpoint
+}
+
+func (w *worker) Collect() error {
+	client, address := w.endpoint.getClient()
+	target, err := resolve(address, w.path)
+	if err != nil {
+		return err
+	}
+	var body io.Reader
+	if w.endpoint.body != "" {
+		body = strings.NewReader(w.endpoint.body)
+	}
+	req, err := http.NewRequest(w.endpoint.method, target, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s %s returned %s: %s", w.endpoint.method, target, resp.Status, bytes.TrimSpace(raw))
+	}
+	value, err := Extract(w.jsonPath, raw)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", w.endpoint.method, target, err)
+	}
+	w.Append(collector.Metric{
+		Value:     value,
+		TimeStamp: time.Now(),
+	})
+	return nil
+}
+
+func resolve(address, path string) (string, error) {
+	if path == "" {
+		return address, nil
+	}
+	base, err := url.Parse(address)
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(path)
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+// Extract evaluates the jsonpath on a json document, it must resolve to a single number
+// or a string holding one
+func Extract(jp *jsonpath.JSONPath, raw []byte) (float64, error) {
+	var data interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		return 0, fmt.Errorf("invalid json response: %w", err)
+	}
+	results, err := jp.FindResults(data)
+	if err != nil {
+		return 0, err
+	}
+	var values []reflect.Value
+	for _, r := range results {
+		values = append(values, r...)
+	}
+	if len(values) == 0 {
+		return 0, errs.EMPTY_RESULT
+	}
+	if len(values) > 1 {
+		return 0, fmt.Errorf("jsonpath resolved to %d values, expect one", len(values))
+	}
+	v := values[0]
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return 0, errs.EMPTY_RESULT
+	}
+	switch val := v.Interface().(type) {
+	case json.Number:
+		return val.Float64()
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(val), 64)
+	case bool:
+		if val {
+			return 1, nil
+		}
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("jsonpath resolved to %v, expect a number", val)
+	}
+}
+
+func (w *worker) NoModelKey() string {
+	return w.key
+}
@@ -0,0 +1,98 @@
+package http_collector
+
+import (
+	"errors"
+	"fmt"
+	"github.com/LL-res/AOM/collector"
+	"github.com/LL-res/AOM/common/basetype"
+	"github.com/LL-res/AOM/common/errs"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query, path, expr string
+	}{
+		{query: "{.inFlight}", expr: "{.inFlight}"},
+		{query: ".inFlight", expr: "{.inFlight}"},
+		{query: "$.orders.count", expr: "{$.orders.count}"},
+		{query: "/api/stats {.inFlight}", path: "/api/stats", expr: "{.inFlight}"},
+		{query: "http://other:8080/stats  .queue", path: "http://other:8080/stats", expr: "{.queue}"},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			path, expr := ParseQuery(tt.query)
+			if path != tt.path || expr != tt.expr {
+				t.Errorf("got %q %q, want %q %q", path, expr, tt.path, tt.expr)
+			}
+		})
+	}
+}
+
+func TestCollect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/stats":
+			if r.Method != http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			body, _ := io.ReadAll(r.Body)
+			fmt.Fprintf(w, `{"echo": %s, "inFlight": 42, "ratio": "0.5", "queues": [{"name": "orders", "depth": 7}, {"name": "mails", "depth": 3}], "none": null}`, body)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("boom"))
+		}
+	}))
+	defer server.Close()
+	tests := []struct {
+		query   string
+		want    float64
+		wantErr error
+	}{
+		{query: "/stats {.inFlight}", want: 42},
+		{query: "/stats .ratio", want: 0.5},
+		{query: `/stats {.queues[?(@.name=="orders")].depth}`, want: 7},
+		{query: "/stats {.echo.n}", want: 1},
+		{query: "/stats {.queues[*].depth}", wantErr: errors.New("2 values")},
+		{query: "/stats {.missing}", wantErr: errs.EMPTY_RESULT},
+		{query: "/stats {.none}", wantErr: errs.EMPTY_RESULT},
+		{query: "/stats {.queues}", wantErr: errors.New("not a number")},
+		{query: "/broken {.inFlight}", wantErr: errors.New("500")},
+	}
+	c := New(http.MethodPost, `{"n": 1}`, time.Second)
+	if err := c.SetServerAddress(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			metric := basetype.Metric{Name: fmt.Sprintf("m%d", i), Query: tt.query}
+			c.AddCustomMetrics(metric, metric.Query)
+			w, err := c.CreateWorker(metric)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = w.Collect()
+			if (err != nil) != (tt.wantErr != nil) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == errs.EMPTY_RESULT && !errors.Is(err, errs.EMPTY_RESULT) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got, ok := w.(collector.LatestCollector).Latest()
+			if !ok || got.Value != tt.want {
+				t.Errorf("got %v, want %v", got.Value, tt.want)
+			}
+		})
+	}
+	if _, err := c.CreateWorker(basetype.Metric{Name: "undefined"}); err == nil {
+		t.Error("expected an error for an undefined metric")
+	}
+}
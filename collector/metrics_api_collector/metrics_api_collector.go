@@ -0,0 +1,198 @@
+package metrics_api_collector
+
+import (
+	"errors"
+	"fmt"
+	"github.com/LL-res/AOM/clients/k8s"
+	"github.com/LL-res/AOM/collector"
+	"github.com/LL-res/AOM/common/basetype"
+	"github.com/LL-res/AOM/common/errs"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	"regexp"
+	"sync"
+	"time"
+)
+
+const (
+	aggregationAvg = "avg"
+	aggregationSum = "sum"
+	aggregationMax = "max"
+	aggregationMin = "min"
+)
+
+// query looks like cpu, memory or aggregation(resource), e.g. sum(cpu), the aggregation defaults to avg
+var queryRegexp = regexp.MustCompile(`^\s*(?:(avg|sum|max|min)\s*\(\s*(cpu|memory)\s*\)|(cpu|memory))\s*$`)
+
+// MetricsAPI reads the cpu and memory usage of the scale target's pods from metrics.k8s.io,
+// cpu is reported in millicores and memory in MiB
+type MetricsAPI struct {
+	collector.CollectorBase
+	namespace      string
+	scaleTargetRef autoscalingv2.CrossVersionObjectReference
+	// guards MetricQL
+	mu sync.RWMutex
+}
+
+func New(namespace string, scaleTargetRef autoscalingv2.CrossVersionObjectReference) collector.Collector {
+	metricQL := make(map[basetype.Metric]string)
+	metricQL[basetype.Metric{Name: "avg_pod_cpu_usage", Unit: "m"}] = "avg(cpu)"
+	metricQL[basetype.Metric{Name: "avg_pod_memory_usage", Unit: "Mi"}] = "avg(memory)"
+	res := &MetricsAPI{
+		namespace:      namespace,
+		scaleTargetRef: scaleTargetRef,
+	}
+	res.MetricQL = metricQL
+	return res
+}
+
+// SetServerAddress only records the address, the metrics api is reached through the kubernetes api server
+func (m *MetricsAPI) SetServerAddress(url string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ServerAddress = url
+	return nil
+}
+
+func (m *MetricsAPI) ListMetricTypes() []basetype.Metric {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := make([]basetype.Metric, 0, len(m.MetricQL))
+	for metric := range m.MetricQL {
+		result = append(result, metric)
+	}
+	return result
+}
+
+func (m *MetricsAPI) AddCustomMetrics(metric basetype.Metric, query string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.MetricQL[metric] = query
+}
+
+func (m *MetricsAPI) CreateWorker(metric basetype.Metric) (collector.MetricCollector, error) {
+	m.mu.RLock()
+	query, ok := m.MetricQL[metric]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, errors.New("undefined metric type")
+	}
+	aggregation, resource, err := parseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return &worker{
+		MetricType: collector.MetricType{
+			Name: metric.Name,
+			Unit: metric.Unit,
+		},
+		key:            metric.NoModelKey(),
+		query:          query,
+		aggregation:    aggregation,
+		resource:       resource,
+		namespace:      m.namespace,
+		scaleTargetRef: m.scaleTargetRef,
+	}, nil
+}
+
+func parseQuery(query string) (aggregation string, resource corev1.ResourceName, err error) {
+	match := queryRegexp.FindStringSubmatch(query)
+	if match == nil {
+		return "", "", fmt.Errorf("invalid metrics api query [%s], expect cpu, memory or avg|sum|max|min(cpu|memory)", query)
+	}
+	if match[3] != "" {
+		return aggregationAvg, corev1.ResourceName(match[3]), nil
+	}
+	return match[1], corev1.ResourceName(match[2]), nil
+}
+
+type worker struct {
+	collector.MetricType
+	collector.Buffer
+	key            string
+	query          string
+	aggregation    string
+	resource       corev1.ResourceName
+	namespace      string
+	scaleTargetRef autoscalingv2.CrossVersionObjectReference
+	// metrics server refreshes its data less often than aom may scrape,
+	// the same sample is only kept once
+	lastTimeStamp time.Time
+}
+
+func (w *worker) Collect() error {
+	selector, err := k8s.GlobalClient.GetSelector(w.namespace, w.scaleTargetRef)
+	if err != nil {
+		return err
+	}
+	pods, err := k8s.GlobalClient.ListPodMetrics(w.namespace, selector)
+	if err != nil {
+		return err
+	}
+	if len(pods) == 0 {
+		return fmt.Errorf("%w: no pod metrics found for selector [%s]", errs.EMPTY_RESULT, selector)
+	}
+	value, timeStamp := aggregate(pods, w.resource, w.aggregation)
+	if !timeStamp.After(w.lastTimeStamp) {
+		return nil
+	}
+	w.lastTimeStamp = timeStamp
+	w.Append(collector.Metric{
+		Value:     value,
+		TimeStamp: timeStamp,
+	})
+	return nil
+}
+
+// aggregate sums the usage of the containers in every pod and aggregates the pods,
+// the time stamp returned is the latest one among the pods
+func aggregate(pods []k8s.PodMetrics, resource corev1.ResourceName, aggregation string) (float64, time.Time) {
+	values := make([]float64, 0, len(pods))
+	var timeStamp time.Time
+	for _, pod := range pods {
+		usage := 0.0
+		for _, container := range pod.Containers {
+			quantity, ok := container.Usage[resource]
+			if !ok {
+				continue
+			}
+			if resource == corev1.ResourceCPU {
+				usage += float64(quantity.MilliValue())
+			} else {
+				usage += float64(quantity.Value()) / (1 << 20)
+			}
+		}
+		values = append(values, usage)
+		if pod.Timestamp.Time.After(timeStamp) {
+			timeStamp = pod.Timestamp.Time
+		}
+	}
+	res := values[0]
+	switch aggregation {
+	case aggregationSum, aggregationAvg:
+		res = 0
+		for _, v := range values {
+			res += v
+		}
+		if aggregation == aggregationAvg {
+			res /= float64(len(values))
+		}
+	case aggregationMax:
+		for _, v := range values {
+			if v > res {
+				res = v
+			}
+		}
+	case aggregationMin:
+		for _, v := range values {
+			if v < res {
+				res = v
+			}
+		}
+	}
+	return res, timeStamp
+}
+
+func (w *worker) NoModelKey() string {
+	return w.key
+}
@@ -0,0 +1,79 @@
+package metrics_api_collector
+
+import (
+	"fmt"
+	"github.com/LL-res/AOM/clients/k8s"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+	"time"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query       string
+		aggregation string
+		resource    corev1.ResourceName
+		wantErr     bool
+	}{
+		{query: "cpu", aggregation: aggregationAvg, resource: corev1.ResourceCPU},
+		{query: " sum( memory ) ", aggregation: aggregationSum, resource: corev1.ResourceMemory},
+		{query: "max(cpu)", aggregation: aggregationMax, resource: corev1.ResourceCPU},
+		{query: "rate(cpu)", wantErr: true},
+		{query: "sum(http_requests_total)", wantErr: true},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			aggregation, res, err := parseQuery(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if aggregation != tt.aggregation || res != tt.resource {
+				t.Errorf("got %s(%s), want %s(%s)", aggregation, res, tt.aggregation, tt.resource)
+			}
+		})
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	now := time.Now()
+	pod := func(ts time.Time, cpu ...string) k8s.PodMetrics {
+		p := k8s.PodMetrics{Timestamp: metav1.NewTime(ts)}
+		for _, c := range cpu {
+			p.Containers = append(p.Containers, k8s.ContainerMetrics{
+				Usage: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse(c),
+					corev1.ResourceMemory: resource.MustParse("64Mi"),
+				},
+			})
+		}
+		return p
+	}
+	pods := []k8s.PodMetrics{
+		pod(now.Add(-time.Second), "100m", "50m"),
+		pod(now, "250m"),
+	}
+	tests := []struct {
+		resource    corev1.ResourceName
+		aggregation string
+		want        float64
+	}{
+		{resource: corev1.ResourceCPU, aggregation: aggregationAvg, want: 200},
+		{resource: corev1.ResourceCPU, aggregation: aggregationSum, want: 400},
+		{resource: corev1.ResourceCPU, aggregation: aggregationMax, want: 250},
+		{resource: corev1.ResourceCPU, aggregation: aggregationMin, want: 150},
+		{resource: corev1.ResourceMemory, aggregation: aggregationSum, want: 192},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			got, ts := aggregate(pods, tt.resource, tt.aggregation)
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if !ts.Equal(now) {
+				t.Errorf("got time stamp %v, want the latest one %v", ts, now)
+			}
+		})
+	}
+}
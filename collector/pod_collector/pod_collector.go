@@ -0,0 +1,388 @@
+package pod_collector
+
+import (
+	"errors"
+	"fmt"
+	"github.com/LL-res/AOM/clients/k8s"
+	"github.com/LL-res/AOM/collector"
+	"github.com/LL-res/AOM/collector/selector"
+	"github.com/LL-res/AOM/common/basetype"
+	"github.com/LL-res/AOM/common/errs"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	aggregationAvg = "avg"
+	aggregationSum = "sum"
+	aggregationMax = "max"
+	aggregationMin = "min"
+
+	defaultPath    = "/metrics"
+	defaultTimeout = 5 * time.Second
+	// ask for the prometheus text format, openmetrics stamps and suffixes counters differently
+	acceptHeader = "text/plain;version=0.0.4;q=1,*/*;q=0.1"
+)
+
+var queryRe = regexp.MustCompile(`^\s*(avg|sum|max|min)\s*\((.*)\)\s*$`)
+
+// Target is a pod to scrape
+type Target struct {
+	Name    string
+	Address string
+}
+
+// PodScrape scrapes the /metrics endpoint of the scale target's pods, the query of a metric is a series
+// selector optionally wrapped in avg, sum, max or min aggregating the pods, sum by default,
+// e.g. avg(http_requests_total{code="200"}). The series of counters are turned into per second rates
+type PodScrape struct {
+	collector.CollectorBase
+	scheme  string
+	port    int
+	path    string
+	targets func() ([]Target, error)
+	client  *http.Client
+	// guards MetricQL, ServerAddress and client
+	mu sync.RWMutex
+}
+
+func New(namespace string, scaleTargetRef autoscalingv2.CrossVersionObjectReference, scheme string, port int, path string) collector.Collector {
+	return newPodScrape(scheme, port, path, func() ([]Target, error) {
+		return listTargets(namespace, scaleTargetRef)
+	})
+}
+
+func newPodScrape(scheme string, port int, path string, targets func() ([]Target, error)) *PodScrape {
+	if scheme == "" {
+		scheme = "http"
+	}
+	if path == "" {
+		path = defaultPath
+	}
+	res := &PodScrape{
+		scheme:  scheme,
+		port:    port,
+		path:    path,
+		targets: targets,
+		client:  &http.Client{Timeout: defaultTimeout},
+	}
+	res.MetricQL = make(map[basetype.Metric]string)
+	return res
+}
+
+// listTargets returns the running pods of the scale target that have an ip
+func listTargets(namespace string, scaleTargetRef autoscalingv2.CrossVersionObjectReference) ([]Target, error) {
+	sel, err := k8s.GlobalClient.GetSelector(namespace, scaleTargetRef)
+	if err != nil {
+		return nil, err
+	}
+	pods, err := k8s.GlobalClient.ListPods(namespace, sel)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Target, 0, len(pods))
+	for _, pod := range pods {
+		if pod.Status.Phase != corev1.PodRunning || pod.Status.PodIP == "" || pod.DeletionTimestamp != nil {
+			continue
+		}
+		res = append(res, Target{Name: pod.Name, Address: pod.Status.PodIP})
+	}
+	return res, nil
+}
+
+// SetServerAddress only records the address, the pods are found through the scale target
+func (p *PodScrape) SetServerAddress(url string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ServerAddress = url
+	return nil
+}
+
+func (p *PodScrape) SetRoundTripper(rt http.RoundTripper) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.client = &http.Client{Transport: rt, Timeout: defaultTimeout}
+	return nil
+}
+
+func (p *PodScrape) getClient() *http.Client {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.client
+}
+
+func (p *PodScrape) ListMetricTypes() []basetype.Metric {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	result := make([]basetype.Metric, 0, len(p.MetricQL))
+	for m := range p.MetricQL {
+		result = append(result, m)
+	}
+	return result
+}
+
+func (p *PodScrape) AddCustomMetrics(metric basetype.Metric, query string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.MetricQL[metric] = query
+}
+
+func (p *PodScrape) CreateWorker(metric basetype.Metric) (collector.MetricCollector, error) {
+	p.mu.RLock()
+	query, ok := p.MetricQL[metric]
+	p.mu.RUnlock()
+	if !ok {
+		return nil, errors.New("undefined metric type")
+	}
+	aggregation, sel, err := ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return &worker{
+		MetricType: collector.MetricType{
+			Name: metric.Name,
+			Unit: metric.Unit,
+		},
+		key:         metric.NoModelKey(),
+		aggregation: aggregation,
+		selector:    sel,
+		scrape:      p,
+		counters:    make(map[string]map[string]counterSample),
+		now:         time.Now,
+	}, nil
+}
+
+// ParseQuery splits a query into its aggregation and its selector, the selector must name a metric
+func ParseQuery(query string) (string, selector.Selector, error) {
+	aggregation := aggregationSum
+	if match := queryRe.FindStringSubmatch(query); match != nil {
+		aggregation, query = match[1], match[2]
+	}
+	sel, err := selector.Parse(query)
+	if err != nil {
+		return "", nil, err
+	}
+	if sel.Name() == "" {
+		return "", nil, fmt.Errorf("query [%s] names no metric", query)
+	}
+	return aggregation, sel, nil
+}
+
+type counterSample struct {
+	value     float64
+	timeStamp time.Time
+}
+
+type worker struct {
+	collector.MetricType
+	collector.Buffer
+	key         string
+	aggregation string
+	selector    selector.Selector
+	scrape      *PodScrape
+	// pod -> series -> the last sample of the counters, to compute the rates
+	counters map[string]map[string]counterSample
+	now      func() time.Time
+}
+
+type podResult struct {
+	name     string
+	families map[string]*dto.MetricFamily
+	err      error
+}
+
+// Collect scrapes all the pods at once and aggregates the value of every pod,
+// nothing is appended on the first scrape of a counter since a rate needs two
+func (w *worker) Collect() error {
+	targets, err := w.scrape.targets()
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("%w: no running pod to scrape", errs.EMPTY_RESULT)
+	}
+	now := w.now()
+	results := make([]podResult, len(targets))
+	wg := sync.WaitGroup{}
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target Target) {
+			defer wg.Done()
+			families, err := w.scrape.fetch(target)
+			results[i] = podResult{name: target.Name, families: families, err: err}
+		}(i, target)
+	}
+	wg.Wait()
+
+	values := make([]float64, 0, len(results))
+	seen := make(map[string]struct{}, len(results))
+	var failures []string
+	for _, r := range results {
+		seen[r.name] = struct{}{}
+		if r.err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", r.name, r.err))
+			continue
+		}
+		if value, ok := w.podValue(r.name, r.families, now); ok {
+			values = append(values, value)
+		}
+	}
+	// the pods gone take their counters with them
+	for name := range w.counters {
+		if _, ok := seen[name]; !ok {
+			delete(w.counters, name)
+		}
+	}
+	if len(failures) == len(results) {
+		return fmt.Errorf("scrape failed for all pods: %s", strings.Join(failures, "; "))
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	w.Append(collector.Metric{
+		Value:     aggregate(values, w.aggregation),
+		TimeStamp: now,
+	})
+	return nil
+}
+
+func (p *PodScrape) fetch(target Target) (map[string]*dto.MetricFamily, error) {
+	host := net.JoinHostPort(target.Address, strconv.Itoa(p.port))
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s://%s%s", p.scheme, host, p.path), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", acceptHeader)
+	resp, err := p.getClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	parser := expfmt.TextParser{}
+	return parser.TextToMetricFamilies(resp.Body)
+}
+
+// podValue sums the matching series of a pod, counters contribute their rate since the last scrape
+func (w *worker) podValue(pod string, families map[string]*dto.MetricFamily, now time.Time) (float64, bool) {
+	family, ok := families[w.selector.Name()]
+	if !ok {
+		return 0, false
+	}
+	isCounter := family.GetType() == dto.MetricType_COUNTER
+	if !isCounter && strings.HasSuffix(family.GetName(), "_total") {
+		// exposed in openmetrics, the type is declared on the name without _total
+		if base, ok := families[strings.TrimSuffix(family.GetName(), "_total")]; ok && base.GetType() == dto.MetricType_COUNTER {
+			isCounter = true
+		}
+	}
+	last := w.counters[pod]
+	if isCounter {
+		w.counters[pod] = make(map[string]counterSample)
+	}
+	sum, found := 0.0, false
+	for _, m := range family.GetMetric() {
+		labels := map[string]string{selector.MetricNameLabel: family.GetName()}
+		for _, l := range m.GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+		if !w.selector.Matches(labels) {
+			continue
+		}
+		value, ok := sampleValue(m)
+		if !ok {
+			continue
+		}
+		if !isCounter {
+			sum += value
+			found = true
+			continue
+		}
+		series := seriesKey(labels)
+		w.counters[pod][series] = counterSample{value: value, timeStamp: now}
+		prev, ok := last[series]
+		if !ok || !now.After(prev.timeStamp) {
+			continue
+		}
+		increase := value - prev.value
+		if increase < 0 {
+			// the counter was reset, e.g. the container restarted
+			increase = value
+		}
+		sum += increase / now.Sub(prev.timeStamp).Seconds()
+		found = true
+	}
+	return sum, found
+}
+
+func sampleValue(m *dto.Metric) (float64, bool) {
+	switch {
+	case m.Counter != nil:
+		return m.Counter.GetValue(), true
+	case m.Gauge != nil:
+		return m.Gauge.GetValue(), true
+	case m.Untyped != nil:
+		return m.Untyped.GetValue(), true
+	default:
+		// histograms and summaries are not supported
+		return 0, false
+	}
+}
+
+func seriesKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	b := strings.Builder{}
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(labels[name]))
+		b.WriteByte(',')
+	}
+	return b.String()
+}
+
+func aggregate(values []float64, aggregation string) float64 {
+	res := values[0]
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+		switch aggregation {
+		case aggregationMax:
+			if v > res {
+				res = v
+			}
+		case aggregationMin:
+			if v < res {
+				res = v
+			}
+		}
+	}
+	switch aggregation {
+	case aggregationAvg:
+		return sum / float64(len(values))
+	case aggregationSum:
+		return sum
+	default:
+		return res
+	}
+}
+
+func (w *worker) NoModelKey() string {
+	return w.key
+}
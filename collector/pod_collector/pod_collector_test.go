@@ -0,0 +1,152 @@
+package pod_collector
+
+import (
+	"fmt"
+	"github.com/LL-res/AOM/common/basetype"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query       string
+		aggregation string
+		wantErr     bool
+	}{
+		{query: "http_requests_total", aggregation: "sum"},
+		{query: `avg(http_requests_total{code="200"})`, aggregation: "avg"},
+		{query: ` max ( queue_depth ) `, aggregation: "max"},
+		{query: `{code="200"}`, wantErr: true},
+		{query: `avg(http_requests_total{code=200})`, wantErr: true},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			aggregation, _, err := ParseQuery(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if aggregation != tt.aggregation {
+				t.Errorf("got %s, want %s", aggregation, tt.aggregation)
+			}
+		})
+	}
+}
+
+// pods serves the exposition of every pod, told apart by the host they are reached at
+type pods struct {
+	mu   sync.Mutex
+	text map[string]string
+}
+
+func (p *pods) set(host, text string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.text[host] = text
+}
+
+func (p *pods) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	host, _, _ := net.SplitHostPort(r.Host)
+	text, ok := p.text[host]
+	if !ok || r.URL.Path != "/custom" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Write([]byte(text))
+}
+
+func exposition(requests map[string]float64, inFlight float64) string {
+	b := strings.Builder{}
+	b.WriteString("# HELP http_requests_total The requests served.\n# TYPE http_requests_total counter\n")
+	for code, v := range requests {
+		fmt.Fprintf(&b, "http_requests_total{code=%q} %v\n", code, v)
+	}
+	fmt.Fprintf(&b, "# TYPE in_flight gauge\nin_flight %v\n", inFlight)
+	return b.String()
+}
+
+func TestCollect(t *testing.T) {
+	p := &pods{text: make(map[string]string)}
+	server := httptest.NewServer(p)
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+	port, _ := strconv.Atoi(u.Port())
+	targets := []Target{{Name: "a", Address: "127.0.0.1"}, {Name: "b", Address: "localhost"}}
+	scrape := newPodScrape("", port, "/custom", func() ([]Target, error) {
+		return targets, nil
+	})
+	newWorker := func(query string) *worker {
+		metric := basetype.Metric{Name: query, Query: query}
+		scrape.AddCustomMetrics(metric, query)
+		w, err := scrape.CreateWorker(metric)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return w.(*worker)
+	}
+	requests := newWorker(`sum(http_requests_total{code=~"2.."})`)
+	inFlight := newWorker("avg(in_flight)")
+	start := time.Now()
+	steps := []struct {
+		a, b string
+		// the values expected after the step, nil when nothing is appended
+		requests, inFlight *float64
+	}{
+		{
+			a:        exposition(map[string]float64{"200": 100, "201": 10, "500": 7}, 4),
+			b:        exposition(map[string]float64{"200": 50}, 2),
+			inFlight: float(3),
+		},
+		{
+			// 10s later a served 60 more, b restarted and served 20 since
+			a:        exposition(map[string]float64{"200": 150, "201": 20, "500": 70}, 6),
+			b:        exposition(map[string]float64{"200": 20}, 2),
+			requests: float(8),
+			inFlight: float(4),
+		},
+	}
+	for i, step := range steps {
+		p.set("127.0.0.1", step.a)
+		p.set("localhost", step.b)
+		now := start.Add(time.Duration(i) * 10 * time.Second)
+		for _, w := range []*worker{requests, inFlight} {
+			w.now = func() time.Time { return now }
+			if err := w.Collect(); err != nil {
+				t.Fatalf("step %d: %v", i, err)
+			}
+		}
+		for _, c := range []struct {
+			w    *worker
+			want *float64
+		}{{requests, step.requests}, {inFlight, step.inFlight}} {
+			got := c.w.Send()
+			if c.want == nil && len(got) != 0 {
+				t.Errorf("step %d: got %v, want nothing", i, got)
+			}
+			if c.want != nil && (len(got) != 1 || got[0].Value != *c.want) {
+				t.Errorf("step %d: got %v, want %v", i, got, *c.want)
+			}
+		}
+	}
+	// one pod down still yields the others
+	p.set("localhost", "broken{")
+	if err := inFlight.Collect(); err != nil {
+		t.Errorf("got %v with one pod down", err)
+	}
+	p.set("127.0.0.1", "broken{")
+	if err := inFlight.Collect(); err == nil {
+		t.Error("expected an error with all pods down")
+	}
+}
+
+func float(v float64) *float64 {
+	return &v
+}
@@ -3,12 +3,14 @@ package prometheus_collector
 import (
 	"context"
 	"errors"
-	"fmt"
 	"github.com/LL-res/AOM/collector"
 	"github.com/LL-res/AOM/common/basetype"
+	"github.com/LL-res/AOM/common/errs"
 	"github.com/prometheus/client_golang/api"
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
+	"net/http"
+	"sync"
 
 	"time"
 )
@@ -17,6 +19,10 @@ type Promc struct {
 	collector.CollectorBase
 	//prometheus client
 	client api.Client
+	// nil means the default round tripper of the prometheus client
+	roundTripper http.RoundTripper
+	// guards MetricQL, ServerAddress, roundTripper and client
+	mu sync.RWMutex
 }
 
 func New() collector.Collector {
@@ -31,19 +37,46 @@ func New() collector.Collector {
 	return res
 }
 func (p *Promc) SetServerAddress(url string) error {
-	p.ServerAddress = url
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.newClient(url, p.roundTripper)
+}
+
+func (p *Promc) SetRoundTripper(rt http.RoundTripper) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ServerAddress == "" {
+		p.roundTripper = rt
+		return nil
+	}
+	return p.newClient(p.ServerAddress, rt)
+}
+
+// newClient must be called with mu held
+func (p *Promc) newClient(url string, rt http.RoundTripper) error {
 	client, err := api.NewClient(api.Config{
-		Address: p.ServerAddress,
+		Address:      url,
+		RoundTripper: rt,
 	})
 	if err != nil {
 		return err
 	}
+	p.ServerAddress = url
+	p.roundTripper = rt
 	p.client = client
-
 	return nil
 }
 
+// getClient returns the current client, so workers follow address changes
+func (p *Promc) getClient() api.Client {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.client
+}
+
 func (p *Promc) ListMetricTypes() []basetype.Metric {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
 	result := make([]basetype.Metric, 0, len(p.MetricQL))
 
 	for m := range p.MetricQL {
@@ -52,12 +85,16 @@ func (p *Promc) ListMetricTypes() []basetype.Metric {
 	return result
 }
 
-func (p *Promc) AddCustomMetrics(metricType basetype.Metric) {
-	p.MetricQL[metricType] = metricType.Query
+func (p *Promc) AddCustomMetrics(metricType basetype.Metric, query string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.MetricQL[metricType] = query
 }
 
 func (p *Promc) CreateWorker(MetricType basetype.Metric) (collector.MetricCollector, error) {
+	p.mu.RLock()
 	promql, ok := p.MetricQL[MetricType]
+	p.mu.RUnlock()
 	if !ok {
 		return nil, errors.New("undefined metric type")
 	}
@@ -66,47 +103,40 @@ func (p *Promc) CreateWorker(MetricType basetype.Metric) (collector.MetricCollec
 			Name: MetricType.Name,
 			Unit: MetricType.Unit,
 		},
+		key:    MetricType.NoModelKey(),
 		promql: promql,
-		data:   make([]collector.Metric, 0),
-		client: p.client,
+		promc:  p,
 	}, nil
 
 }
 
 type worker struct {
 	collector.MetricType
+	collector.Buffer
+	// the key of the spec metric, promql may be rendered from a template
+	key    string
 	promql string
-	data   []collector.Metric
-	client api.Client
+	promc  *Promc
 }
 
 func (w *worker) Collect() error {
-	v1api := v1.NewAPI(w.client)
+	v1api := v1.NewAPI(w.promc.getClient())
 	result, _, err := v1api.Query(context.Background(), w.promql, time.Now())
 	if err != nil {
 		return err
 	}
 	vector := result.(model.Vector)
+	if len(vector) == 0 {
+		return errs.EMPTY_RESULT
+	}
 	for _, sample := range vector {
-		w.data = append(w.data, collector.Metric{
+		w.Append(collector.Metric{
 			Value:     float64(sample.Value),
 			TimeStamp: sample.Timestamp.Time(),
 		})
 	}
 	return nil
 }
-func (w *worker) Send() []collector.Metric {
-	res := make([]collector.Metric, len(w.data))
-	copy(res, w.data)
-	w.data = make([]collector.Metric, 0)
-	return res
-}
-func (w *worker) DataCap() int {
-	if w.data == nil {
-		return 0
-	}
-	return len(w.data)
-}
-func (w worker) NoModelKey() string {
-	return fmt.Sprintf("%s$%s$%s", w.Name, w.Unit, w.promql)
+func (w *worker) NoModelKey() string {
+	return w.key
 }
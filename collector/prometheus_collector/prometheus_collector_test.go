@@ -8,6 +8,8 @@ import (
 	"github.com/prometheus/client_golang/api"
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 )
@@ -95,7 +97,7 @@ func TestWorker(t *testing.T) {
 		Unit:          "",
 		Query:         "sum(http_requests_total)",
 	}
-	Tcollector.AddCustomMetrics(reqMetric)
+	Tcollector.AddCustomMetrics(reqMetric, reqMetric.Query)
 	fmt.Printf("collector list : %#v", Tcollector.ListMetricTypes())
 	worker, err := Tcollector.CreateWorker(reqMetric)
 	if err != nil {
@@ -116,3 +118,48 @@ func TestWorker(t *testing.T) {
 	fmt.Println("noModelKey :", worker.NoModelKey())
 
 }
+
+func newFakeProm(value string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[%d,"%s"]}]}}`, time.Now().Unix(), value)
+	}))
+}
+
+func TestSeparateInstances(t *testing.T) {
+	serverA, serverB := newFakeProm("1"), newFakeProm("2")
+	defer serverA.Close()
+	defer serverB.Close()
+	metric := basetype.Metric{Name: "up", Query: "up"}
+	tests := []struct {
+		address string
+		want    float64
+	}{
+		{address: serverA.URL, want: 1},
+		{address: serverB.URL, want: 2},
+	}
+	workers := make([]collector.MetricCollector, 0, len(tests))
+	for _, tt := range tests {
+		c := New()
+		if err := c.SetServerAddress(tt.address); err != nil {
+			t.Fatal(err)
+		}
+		c.AddCustomMetrics(metric, metric.Query)
+		worker, err := c.CreateWorker(metric)
+		if err != nil {
+			t.Fatal(err)
+		}
+		workers = append(workers, worker)
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			if err := workers[i].Collect(); err != nil {
+				t.Fatal(err)
+			}
+			data := workers[i].Send()
+			if len(data) != 1 || data[0].Value != tt.want {
+				t.Errorf("got %v, want %v", data, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package collector
+
+import (
+	"bytes"
+	"github.com/prometheus/common/model"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// QueryContext is the data a metric query is rendered with,
+// e.g. sum(rate(http_requests_total{namespace="{{.Namespace}}"}[{{.ScrapeInterval}}]))
+type QueryContext struct {
+	Namespace  string
+	TargetName string
+	TargetKind string
+	// the pod label selector of the scale target, e.g. app=web,tier=frontend
+	Selector string
+	// the equality requirements of Selector, e.g. {{index .SelectorLabels "app"}}
+	SelectorLabels map[string]string
+	// in prometheus duration format, e.g. 15s
+	ScrapeInterval string
+}
+
+// PromDuration formats d the way prometheus parses durations, e.g. 1m rather than 1m0s
+func PromDuration(d time.Duration) string {
+	return model.Duration(d).String()
+}
+
+// IsTemplate reports whether query needs to be rendered
+func IsTemplate(query string) bool {
+	return strings.Contains(query, "{{")
+}
+
+// RenderQuery substitutes the variables in query, referring to an unknown variable is an error
+func RenderQuery(query string, ctx QueryContext) (string, error) {
+	if !IsTemplate(query) {
+		return query, nil
+	}
+	tmpl, err := template.New("query").Option("missingkey=error").Parse(query)
+	if err != nil {
+		return "", err
+	}
+	buf := bytes.Buffer{}
+	if err := tmpl.Execute(&buf, ctx); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,67 @@
+package collector
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRenderQuery(t *testing.T) {
+	ctx := QueryContext{
+		Namespace:      "shop",
+		TargetName:     "web",
+		TargetKind:     "Deployment",
+		Selector:       "app=web,tier=frontend",
+		SelectorLabels: map[string]string{"app": "web", "tier": "frontend"},
+		ScrapeInterval: "15s",
+	}
+	tests := []struct {
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{query: "sum(up)", want: "sum(up)"},
+		{
+			query: `sum(rate(http_requests_total{namespace="{{.Namespace}}",pod=~"{{.TargetName}}-.*"}[{{.ScrapeInterval}}]))`,
+			want:  `sum(rate(http_requests_total{namespace="shop",pod=~"web-.*"}[15s]))`,
+		},
+		{query: `up{app="{{index .SelectorLabels "app"}}",kind="{{.TargetKind}}"}`, want: `up{app="web",kind="Deployment"}`},
+		{query: "{{.Unknown}}", wantErr: true},
+		{query: "{{.Namespace", wantErr: true},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			got, err := RenderQuery(tt.query, ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPromDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{d: 15 * time.Second, want: "15s"},
+		{d: 60 * time.Second, want: "1m"},
+		{d: 90 * time.Second, want: "1m30s"},
+		{d: time.Hour, want: "1h"},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			if got := PromDuration(tt.d); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+	// a range selector prometheus accepts
+	got, err := RenderQuery("rate(x[{{.ScrapeInterval}}])", QueryContext{ScrapeInterval: PromDuration(60 * time.Second)})
+	if err != nil || got != "rate(x[1m])" {
+		t.Errorf("got %s, %v", got, err)
+	}
+}
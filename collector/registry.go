@@ -0,0 +1,171 @@
+package collector
+
+import (
+	"errors"
+	"github.com/LL-res/AOM/common/errs"
+	"github.com/LL-res/AOM/log"
+	"sync"
+	"time"
+)
+
+// WorkerKey identifies the workers that collect the same series, so that instances watching it can share one
+type WorkerKey struct {
+	Address string
+	// the rendered query
+	Query    string
+	Interval time.Duration
+	// tells apart the workers that must not be shared although they query the same, e.g. with other credentials
+	Scope string
+}
+
+// Registry runs one worker for every WorkerKey no matter how many instances subscribe to it,
+// the worker stops once the last subscription is released
+type Registry struct {
+	workers map[WorkerKey]*sharedWorker
+	mu      sync.Mutex
+}
+
+func NewRegistry() *Registry {
+	return &Registry{workers: make(map[WorkerKey]*sharedWorker)}
+}
+
+type sharedWorker struct {
+	key    WorkerKey
+	worker MetricCollector
+	health *Health
+	subs   map[*Subscription]struct{}
+	stopC  chan struct{}
+	// the last metric handed to the subscriptions
+	last    Metric
+	hasLast bool
+	// guards subs, last and hasLast
+	mu sync.Mutex
+}
+
+// Subscription is the view of a shared worker an instance holds, the metrics collected by the worker are
+// appended to every subscription, so Send only drains the buffer of the instance calling it
+type Subscription struct {
+	Buffer
+	noModelKey string
+	shared     *sharedWorker
+}
+
+// Acquire subscribes to the worker of key, create builds the worker when there is none yet
+func (r *Registry) Acquire(key WorkerKey, noModelKey string, create func() (MetricCollector, error)) (*Subscription, error) {
+	if key.Interval <= 0 {
+		return nil, errors.New("the interval of a shared worker must be positive")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	shared, ok := r.workers[key]
+	if !ok {
+		worker, err := create()
+		if err != nil {
+			return nil, err
+		}
+		shared = &sharedWorker{
+			key:    key,
+			worker: worker,
+			health: NewHealth(time.Now()),
+			subs:   make(map[*Subscription]struct{}),
+			stopC:  make(chan struct{}),
+		}
+		r.workers[key] = shared
+		go shared.run()
+		log.Logger.Info("start shared worker", "address", key.Address, "query", key.Query, "interval", key.Interval)
+	}
+	sub := &Subscription{noModelKey: noModelKey, shared: shared}
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+	if shared.hasLast {
+		// the instance joining a running worker needs not wait a whole interval for a sample
+		sub.Append(shared.last)
+	}
+	shared.subs[sub] = struct{}{}
+	return sub, nil
+}
+
+// Release unsubscribes, releasing a subscription twice is a no-op
+func (r *Registry) Release(sub *Subscription) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	shared := sub.shared
+	shared.mu.Lock()
+	delete(shared.subs, sub)
+	left := len(shared.subs)
+	shared.mu.Unlock()
+	if left != 0 || r.workers[shared.key] != shared {
+		return
+	}
+	delete(r.workers, shared.key)
+	close(shared.stopC)
+	log.Logger.Info("stop shared worker", "address", shared.key.Address, "query", shared.key.Query, "interval", shared.key.Interval)
+}
+
+// Subscribers returns how many subscriptions the worker of key has, 0 when it is not running
+func (r *Registry) Subscribers(key WorkerKey) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	shared, ok := r.workers[key]
+	if !ok {
+		return 0
+	}
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+	return len(shared.subs)
+}
+
+func (s *sharedWorker) run() {
+	ticker := time.NewTicker(s.key.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopC:
+			return
+		case <-ticker.C:
+			s.collect()
+		}
+	}
+}
+
+func (s *sharedWorker) collect() {
+	err := s.worker.Collect()
+	if err != nil && !errors.Is(err, errs.EMPTY_RESULT) {
+		log.Logger.Error(err, "fail to collect", "query", s.key.Query)
+	}
+	var latest Metric
+	var hasLatest bool
+	if l, ok := s.worker.(LatestCollector); ok {
+		latest, hasLatest = l.Latest()
+	}
+	s.health.Observe(time.Now(), err, latest, hasLatest)
+	// the worker is only read here, the subscriptions keep the metrics instead
+	metrics := s.worker.Send()
+	if len(metrics) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sub := range s.subs {
+		sub.Append(metrics...)
+	}
+	s.last, s.hasLast = metrics[len(metrics)-1], true
+}
+
+// Collect is a no-op, the registry collects on behalf of all the subscriptions
+func (s *Subscription) Collect() error {
+	return nil
+}
+
+func (s *Subscription) NoModelKey() string {
+	return s.noModelKey
+}
+
+func (s *Subscription) Key() WorkerKey {
+	return s.shared.key
+}
+
+// Health is shared by all the subscriptions of a worker
+func (s *Subscription) Health() *Health {
+	return s.shared.health
+}
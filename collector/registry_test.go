@@ -0,0 +1,98 @@
+package collector
+
+import (
+	"github.com/LL-res/AOM/log"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingWorker appends how many times it collected
+type countingWorker struct {
+	Buffer
+	collects int64
+}
+
+func (c *countingWorker) Collect() error {
+	n := atomic.AddInt64(&c.collects, 1)
+	c.Append(Metric{Value: float64(n), TimeStamp: time.Now()})
+	return nil
+}
+
+func (c *countingWorker) NoModelKey() string {
+	return "counting"
+}
+
+func TestRegistry(t *testing.T) {
+	log.Init()
+	r := NewRegistry()
+	key := WorkerKey{Address: "http://prometheus:9090", Query: "sum(up)", Interval: 10 * time.Millisecond}
+	created := 0
+	worker := &countingWorker{}
+	create := func() (MetricCollector, error) {
+		created++
+		return worker, nil
+	}
+	a, err := r.Acquire(key, "a", create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := r.Acquire(key, "b", create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created != 1 || r.Subscribers(key) != 2 {
+		t.Fatalf("got %d workers and %d subscribers, want 1 and 2", created, r.Subscribers(key))
+	}
+	if a.Health() != b.Health() || a.NoModelKey() != "a" {
+		t.Error("subscriptions of one worker must share its health and keep their own key")
+	}
+	other := key
+	other.Interval = 20 * time.Millisecond
+	c, err := r.Acquire(other, "c", func() (MetricCollector, error) { return &countingWorker{}, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Release(c)
+
+	time.Sleep(55 * time.Millisecond)
+	sentA := a.Send()
+	if len(sentA) == 0 {
+		t.Fatal("nothing collected")
+	}
+	// a draining its buffer leaves b's alone
+	if b.DataCap() < len(sentA) {
+		t.Errorf("got %d metrics in b, want at least %d", b.DataCap(), len(sentA))
+	}
+
+	// a late subscriber starts from the latest sample
+	late, err := r.Acquire(key, "late", create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest, ok := late.Latest(); !ok || latest.Value < sentA[len(sentA)-1].Value {
+		t.Errorf("got latest %v, want at least %v", latest, sentA[len(sentA)-1])
+	}
+
+	r.Release(a)
+	r.Release(a)
+	r.Release(late)
+	if r.Subscribers(key) != 1 {
+		t.Fatalf("got %d subscribers, want 1", r.Subscribers(key))
+	}
+	r.Release(b)
+	if r.Subscribers(key) != 0 {
+		t.Fatalf("got %d subscribers, want 0", r.Subscribers(key))
+	}
+	stopped := atomic.LoadInt64(&worker.collects)
+	time.Sleep(30 * time.Millisecond)
+	if got := atomic.LoadInt64(&worker.collects); got > stopped+1 {
+		t.Errorf("worker still collecting after the last release, %d collects since", got-stopped)
+	}
+	// acquiring again starts a new worker
+	again, err := r.Acquire(key, "a", create)
+	if err != nil || created != 2 {
+		t.Fatalf("got %d workers, error %v, want a new one", created, err)
+	}
+	r.Release(again)
+}
@@ -0,0 +1,145 @@
+package remote_write_collector
+
+import (
+	"fmt"
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+	"math"
+)
+
+// field numbers of prometheus.WriteRequest, see prompb/remote.proto and prompb/types.proto
+const (
+	writeRequestTimeSeries = 1
+	timeSeriesLabels       = 1
+	timeSeriesSamples      = 2
+	labelName              = 1
+	labelValue             = 2
+	sampleValue            = 1
+	sampleTimestamp        = 2
+)
+
+// the largest request accepted once decompressed, snappy tells the size up front
+const maxDecodedSize = 64 << 20
+
+type Sample struct {
+	Value float64
+	// milliseconds since epoch
+	Timestamp int64
+}
+
+type TimeSeries struct {
+	Labels  map[string]string
+	Samples []Sample
+}
+
+// DecodeWriteRequest decodes a snappy compressed remote-write request body,
+// the fields besides labels and samples, e.g. exemplars and metadata, are skipped
+func DecodeWriteRequest(body []byte) ([]TimeSeries, error) {
+	size, err := snappy.DecodedLen(body)
+	if err != nil {
+		return nil, fmt.Errorf("snappy decode failed: %w", err)
+	}
+	if size > maxDecodedSize {
+		return nil, fmt.Errorf("decoded request of %d bytes exceeds %d bytes", size, maxDecodedSize)
+	}
+	raw, err := snappy.Decode(nil, body)
+	if err != nil {
+		return nil, fmt.Errorf("snappy decode failed: %w", err)
+	}
+	var res []TimeSeries
+	err = walk(raw, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if num != writeRequestTimeSeries || typ != protowire.BytesType {
+			return nil
+		}
+		ts, err := decodeTimeSeries(v)
+		if err != nil {
+			return err
+		}
+		res = append(res, ts)
+		return nil
+	})
+	return res, err
+}
+
+func decodeTimeSeries(b []byte) (TimeSeries, error) {
+	ts := TimeSeries{Labels: make(map[string]string)}
+	err := walk(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case timeSeriesLabels:
+			var name, value string
+			err := walk(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+				if typ != protowire.BytesType {
+					return nil
+				}
+				switch num {
+				case labelName:
+					name = string(v)
+				case labelValue:
+					value = string(v)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			ts.Labels[name] = value
+		case timeSeriesSamples:
+			var s Sample
+			err := walk(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+				switch {
+				case num == sampleValue && typ == protowire.Fixed64Type:
+					bits, n := protowire.ConsumeFixed64(v)
+					if n < 0 {
+						return protowire.ParseError(n)
+					}
+					s.Value = math.Float64frombits(bits)
+				case num == sampleTimestamp && typ == protowire.VarintType:
+					t, n := protowire.ConsumeVarint(v)
+					if n < 0 {
+						return protowire.ParseError(n)
+					}
+					s.Timestamp = int64(t)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			ts.Samples = append(ts.Samples, s)
+		}
+		return nil
+	})
+	return ts, err
+}
+
+// walk calls do with every field of a message, v is the payload of length delimited fields
+// and the raw encoded value of the others
+func walk(b []byte, do func(num protowire.Number, typ protowire.Type, v []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		var v []byte
+		if typ == protowire.BytesType {
+			v, n = protowire.ConsumeBytes(b)
+		} else {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n >= 0 {
+				v = b[:n]
+			}
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		if err := do(num, typ, v); err != nil {
+			return err
+		}
+		b = b[n:]
+	}
+	return nil
+}
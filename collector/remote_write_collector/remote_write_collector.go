@@ -0,0 +1,269 @@
+package remote_write_collector
+
+import (
+	"context"
+	"errors"
+	"github.com/LL-res/AOM/collector"
+	"github.com/LL-res/AOM/collector/selector"
+	"github.com/LL-res/AOM/common/basetype"
+	"github.com/LL-res/AOM/common/errs"
+	"github.com/LL-res/AOM/log"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// WritePath is the path the remote-write endpoint is served at
+	WritePath = "/api/v1/write"
+	// the largest request body accepted, prometheus sends a few hundred KB at most
+	maxRequestSize = 16 << 20
+	// the series matched by a worker are kept up to this many
+	maxSeries = 1000
+	// a series not pushed for this long is left out of the sum, as prometheus marks it stale
+	staleness = 5 * time.Minute
+)
+
+// Server is the remote-write endpoint shared by all the instances, the series pushed to it
+// are routed to the workers of every receiver whose selector they match
+type Server struct {
+	addr string
+	mu   sync.RWMutex
+	// namespace/name of the instance -> its receiver
+	receivers map[string]*Receiver
+}
+
+func NewServer(addr string) *Server {
+	return &Server{
+		addr:      addr,
+		receivers: make(map[string]*Receiver),
+	}
+}
+
+// NewReceiver creates the collector of an instance, a receiver created before for the same
+// instance stops getting series
+func (s *Server) NewReceiver(owner string) collector.Collector {
+	r := &Receiver{
+		workers: make(map[string]*worker),
+	}
+	r.MetricQL = make(map[basetype.Metric]string)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.receivers[owner] = r
+	return r
+}
+
+// RemoveReceiver stops routing series to the receiver of a deleted instance
+func (s *Server) RemoveReceiver(owner string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.receivers, owner)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	if err != nil {
+		code := http.StatusBadRequest
+		if maxErr := (*http.MaxBytesError)(nil); errors.As(err, &maxErr) {
+			code = http.StatusRequestEntityTooLarge
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+	series, err := DecodeWriteRequest(body)
+	if err != nil {
+		log.Logger.Error(err, "fail to decode remote-write request")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.RLock()
+	for _, receiver := range s.receivers {
+		receiver.route(series)
+	}
+	s.mu.RUnlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Start serves the endpoint until ctx is done, it makes the server a manager.Runnable
+func (s *Server) Start(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.Handle(WritePath, s)
+	server := &http.Server{Addr: s.addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	log.Logger.Info("serving remote-write", "address", s.addr, "path", WritePath)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Receiver is the collector of one instance, the query of a metric is a series selector,
+// e.g. http_requests_total{job="api"}
+type Receiver struct {
+	collector.CollectorBase
+	// guards MetricQL, ServerAddress and workers
+	mu sync.RWMutex
+	// noModelKey -> worker
+	workers map[string]*worker
+}
+
+// SetServerAddress is a no-op, the series are pushed to the server
+func (r *Receiver) SetServerAddress(url string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ServerAddress = url
+	return nil
+}
+
+func (r *Receiver) ListMetricTypes() []basetype.Metric {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	result := make([]basetype.Metric, 0, len(r.MetricQL))
+	for m := range r.MetricQL {
+		result = append(result, m)
+	}
+	return result
+}
+
+func (r *Receiver) AddCustomMetrics(metric basetype.Metric, query string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.MetricQL[metric] = query
+}
+
+func (r *Receiver) CreateWorker(metric basetype.Metric) (collector.MetricCollector, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	query, ok := r.MetricQL[metric]
+	if !ok {
+		return nil, errors.New("undefined metric type")
+	}
+	sel, err := selector.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	w := &worker{
+		MetricType: collector.MetricType{
+			Name: metric.Name,
+			Unit: metric.Unit,
+		},
+		key:      metric.NoModelKey(),
+		selector: sel,
+		series:   make(map[string]Sample),
+	}
+	// a recreated worker replaces the old one
+	r.workers[w.key] = w
+	return w, nil
+}
+
+// RemoveWorker stops routing series to the worker of a metric that is gone
+func (r *Receiver) RemoveWorker(noModelKey string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.workers, noModelKey)
+}
+
+func (r *Receiver) route(series []TimeSeries) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, w := range r.workers {
+		for _, ts := range series {
+			if w.selector.Matches(ts.Labels) {
+				w.push(seriesKey(ts.Labels), ts.Samples)
+			}
+		}
+	}
+}
+
+type worker struct {
+	collector.MetricType
+	collector.Buffer
+	key      string
+	selector selector.Selector
+	mu       sync.Mutex
+	// the latest sample of every matching series
+	series map[string]Sample
+	// whether a series got a newer sample since the last collect
+	updated bool
+}
+
+// push keeps the latest sample of the series, a retried push changes nothing
+func (w *worker) push(key string, samples []Sample) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, s := range samples {
+		last, ok := w.series[key]
+		if !ok && len(w.series) >= maxSeries {
+			return
+		}
+		if ok && s.Timestamp <= last.Timestamp {
+			continue
+		}
+		w.series[key] = s
+		w.updated = true
+	}
+}
+
+// Collect sums the latest samples of the series once every scrape interval, so that series pushed at
+// different offsets within the interval are aggregated, the sum is stamped with the latest of them
+func (w *worker) Collect() error {
+	w.mu.Lock()
+	if !w.updated {
+		w.mu.Unlock()
+		return errs.EMPTY_RESULT
+	}
+	w.updated = false
+	var latest int64
+	for _, s := range w.series {
+		if s.Timestamp > latest {
+			latest = s.Timestamp
+		}
+	}
+	sum := 0.0
+	for key, s := range w.series {
+		if time.UnixMilli(latest).Sub(time.UnixMilli(s.Timestamp)) > staleness {
+			delete(w.series, key)
+			continue
+		}
+		sum += s.Value
+	}
+	w.mu.Unlock()
+	w.Append(collector.Metric{
+		Value:     sum,
+		TimeStamp: time.UnixMilli(latest),
+	})
+	return nil
+}
+
+// seriesKey identifies a series by its sorted labels
+func seriesKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	b := strings.Builder{}
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(labels[name])
+		b.WriteByte(',')
+	}
+	return b.String()
+}
+
+func (w *worker) NoModelKey() string {
+	return w.key
+}
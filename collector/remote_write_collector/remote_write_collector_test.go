@@ -0,0 +1,136 @@
+package remote_write_collector
+
+import (
+	"bytes"
+	"github.com/LL-res/AOM/common/basetype"
+	"github.com/LL-res/AOM/common/errs"
+	"github.com/LL-res/AOM/log"
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type series struct {
+	labels  []string
+	samples []Sample
+}
+
+// encode builds a snappy compressed WriteRequest the way remote-write senders do
+func encode(ss ...series) []byte {
+	var req []byte
+	for _, s := range ss {
+		var ts []byte
+		for i := 0; i+1 < len(s.labels); i += 2 {
+			var l []byte
+			l = protowire.AppendTag(l, labelName, protowire.BytesType)
+			l = protowire.AppendString(l, s.labels[i])
+			l = protowire.AppendTag(l, labelValue, protowire.BytesType)
+			l = protowire.AppendString(l, s.labels[i+1])
+			ts = protowire.AppendTag(ts, timeSeriesLabels, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, l)
+		}
+		for _, sample := range s.samples {
+			var b []byte
+			b = protowire.AppendTag(b, sampleValue, protowire.Fixed64Type)
+			b = protowire.AppendFixed64(b, math.Float64bits(sample.Value))
+			b = protowire.AppendTag(b, sampleTimestamp, protowire.VarintType)
+			b = protowire.AppendVarint(b, uint64(sample.Timestamp))
+			ts = protowire.AppendTag(ts, timeSeriesSamples, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, b)
+		}
+		req = protowire.AppendTag(req, writeRequestTimeSeries, protowire.BytesType)
+		req = protowire.AppendBytes(req, ts)
+	}
+	// metadata, field 3, is skipped
+	req = protowire.AppendTag(req, 3, protowire.BytesType)
+	req = protowire.AppendBytes(req, []byte{0x08, 0x01})
+	return snappy.Encode(nil, req)
+}
+
+func TestDecodeWriteRequest(t *testing.T) {
+	body := encode(series{
+		labels:  []string{"__name__", "http_requests_total", "job", "api"},
+		samples: []Sample{{Value: 1.5, Timestamp: 1700000000000}, {Value: 2, Timestamp: 1700000015000}},
+	})
+	got, err := DecodeWriteRequest(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Labels["job"] != "api" || len(got[0].Samples) != 2 || got[0].Samples[1].Value != 2 {
+		t.Errorf("got %+v", got)
+	}
+	if _, err := DecodeWriteRequest([]byte("not snappy")); err == nil {
+		t.Error("expected an error for a corrupted body")
+	}
+}
+
+func TestReceive(t *testing.T) {
+	log.Init()
+	server := NewServer("")
+	receiver := server.NewReceiver("default/aom-sample")
+	metric := basetype.Metric{Name: "requests", Query: `http_requests_total{job="api"}`}
+	receiver.AddCustomMetrics(metric, metric.Query)
+	w, err := receiver.CreateWorker(metric)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Collect(); err != errs.EMPTY_RESULT {
+		t.Errorf("got %v before any push, want %v", err, errs.EMPTY_RESULT)
+	}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+	push := func(body []byte) int {
+		resp, err := http.Post(httpServer.URL+WritePath, "application/x-protobuf", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	a := []string{"__name__", "http_requests_total", "job", "api", "pod", "a"}
+	b := []string{"__name__", "http_requests_total", "job", "api", "pod", "b"}
+	first := encode(
+		series{labels: a, samples: []Sample{{Value: 1, Timestamp: 1000}, {Value: 4, Timestamp: 2000}}},
+		// scraped at another offset within the interval
+		series{labels: b, samples: []Sample{{Value: 2, Timestamp: 2500}}},
+		series{labels: []string{"__name__", "http_requests_total", "job", "web"}, samples: []Sample{{Value: 100, Timestamp: 2000}}},
+	)
+	if code := push(first); code != http.StatusNoContent {
+		t.Fatalf("got status %d", code)
+	}
+	if err := w.Collect(); err != nil {
+		t.Fatal(err)
+	}
+	// a retried push is not counted twice
+	push(first)
+	if err := w.Collect(); err != errs.EMPTY_RESULT {
+		t.Errorf("got %v after a retried push, want %v", err, errs.EMPTY_RESULT)
+	}
+	// series b keeps its latest sample until it is stale
+	push(encode(series{labels: a, samples: []Sample{{Value: 5, Timestamp: 16000}}}))
+	w.Collect()
+	got := w.Send()
+	if len(got) != 2 || got[0].Value != 6 || got[0].TimeStamp.UnixMilli() != 2500 || got[1].Value != 7 {
+		t.Errorf("got %v, want [6 7]", got)
+	}
+	push(encode(series{labels: a, samples: []Sample{{Value: 1, Timestamp: 16000 + staleness.Milliseconds() + 1}}}))
+	w.Collect()
+	if got := w.Send(); len(got) != 1 || got[0].Value != 1 {
+		t.Errorf("got %v, want the stale series b left out", got)
+	}
+	receiver.(*Receiver).RemoveWorker(metric.NoModelKey())
+	push(encode(series{labels: a, samples: []Sample{{Value: 1, Timestamp: 1000000}}}))
+	if err := w.Collect(); err != errs.EMPTY_RESULT {
+		t.Errorf("removed worker still gets series")
+	}
+	large := make([]byte, maxRequestSize+1)
+	if code := push(large); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("got status %d for a too large body", code)
+	}
+	if code := push([]byte("garbage")); code != http.StatusBadRequest {
+		t.Errorf("got status %d for a corrupted body", code)
+	}
+}
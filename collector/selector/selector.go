@@ -0,0 +1,135 @@
+package selector
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MetricNameLabel holds the metric name among the labels of a series
+const MetricNameLabel = "__name__"
+
+type matchType string
+
+const (
+	matchEqual     matchType = "="
+	matchNotEqual  matchType = "!="
+	matchRegexp    matchType = "=~"
+	matchNotRegexp matchType = "!~"
+)
+
+type matcher struct {
+	name  string
+	typ   matchType
+	value string
+	re    *regexp.Regexp
+}
+
+func (m matcher) matches(labels map[string]string) bool {
+	// a missing label matches as the empty string, the same as in promql
+	v := labels[m.name]
+	switch m.typ {
+	case matchEqual:
+		return v == m.value
+	case matchNotEqual:
+		return v != m.value
+	case matchRegexp:
+		return m.re.MatchString(v)
+	default:
+		return !m.re.MatchString(v)
+	}
+}
+
+// Selector is a promql series selector, e.g. http_requests_total{job="api",code=~"5.."}
+type Selector []matcher
+
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, m := range s {
+		if !m.matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// Name returns the metric name the selector requires, empty if it does not require one
+func (s Selector) Name() string {
+	for _, m := range s {
+		if m.name == MetricNameLabel && m.typ == matchEqual {
+			return m.value
+		}
+	}
+	return ""
+}
+
+var (
+	metricNameRe = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*`)
+	labelNameRe  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*`)
+	matchTypeRe  = regexp.MustCompile(`^(=~|!~|!=|=)`)
+	quotedRe     = regexp.MustCompile(`^"(?:[^"\\]|\\.)*"`)
+)
+
+// Parse parses a metric name followed by optional label matchers in braces,
+// either of them may be left out but not both
+func Parse(s string) (Selector, error) {
+	rest := strings.TrimSpace(s)
+	var res Selector
+	if name := metricNameRe.FindString(rest); name != "" {
+		res = append(res, matcher{name: MetricNameLabel, typ: matchEqual, value: name})
+		rest = strings.TrimSpace(rest[len(name):])
+	}
+	if strings.HasPrefix(rest, "{") {
+		rest = strings.TrimSpace(rest[1:])
+		for !strings.HasPrefix(rest, "}") {
+			m, remain, err := parseMatcher(rest)
+			if err != nil {
+				return nil, fmt.Errorf("invalid selector [%s]: %w", s, err)
+			}
+			res = append(res, m)
+			rest = strings.TrimSpace(remain)
+			if strings.HasPrefix(rest, ",") {
+				rest = strings.TrimSpace(rest[1:])
+			} else if !strings.HasPrefix(rest, "}") {
+				return nil, fmt.Errorf("invalid selector [%s]: expect , or }", s)
+			}
+		}
+		rest = strings.TrimSpace(rest[1:])
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("invalid selector [%s]: unexpected [%s]", s, rest)
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("invalid selector [%s]: no metric name nor label matcher", s)
+	}
+	return res, nil
+}
+
+func parseMatcher(s string) (matcher, string, error) {
+	name := labelNameRe.FindString(s)
+	if name == "" {
+		return matcher{}, "", fmt.Errorf("expect a label name at [%s]", s)
+	}
+	s = strings.TrimSpace(s[len(name):])
+	typ := matchTypeRe.FindString(s)
+	if typ == "" {
+		return matcher{}, "", fmt.Errorf("expect one of = != =~ !~ after label [%s]", name)
+	}
+	s = strings.TrimSpace(s[len(typ):])
+	quoted := quotedRe.FindString(s)
+	if quoted == "" {
+		return matcher{}, "", fmt.Errorf("expect a double quoted value for label [%s]", name)
+	}
+	value, err := strconv.Unquote(quoted)
+	if err != nil {
+		return matcher{}, "", err
+	}
+	m := matcher{name: name, typ: matchType(typ), value: value}
+	if m.typ == matchRegexp || m.typ == matchNotRegexp {
+		// regexps match the whole value, the same as in promql
+		if m.re, err = regexp.Compile("^(?:" + value + ")$"); err != nil {
+			return matcher{}, "", err
+		}
+	}
+	return m, s[len(quoted):], nil
+}
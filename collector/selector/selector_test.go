@@ -0,0 +1,39 @@
+package selector
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	labels := map[string]string{"__name__": "http_requests_total", "job": "api", "code": "503"}
+	tests := []struct {
+		selector string
+		want     bool
+		wantErr  bool
+	}{
+		{selector: "http_requests_total", want: true},
+		{selector: `http_requests_total{job="api"}`, want: true},
+		{selector: `{job="api", code=~"5.."}`, want: true},
+		{selector: `http_requests_total{code!~"5..",}`, want: false},
+		{selector: `http_requests_total{code=~"5"}`, want: false},
+		{selector: `{instance!="a", job!="web"}`, want: true},
+		{selector: `{job="a\"b"}`, want: false},
+		{selector: "", wantErr: true},
+		{selector: `{job="api"`, wantErr: true},
+		{selector: `{job=api}`, wantErr: true},
+		{selector: `{code=~"("}`, wantErr: true},
+		{selector: `rate(http_requests_total[1m])`, wantErr: true},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			s, err := Parse(tt.selector)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if err == nil && s.Matches(labels) != tt.want {
+				t.Errorf("got %v, want %v", s.Matches(labels), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,318 @@
+package sql_collector
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/LL-res/AOM/collector"
+	"github.com/LL-res/AOM/common/basetype"
+	"github.com/LL-res/AOM/common/errs"
+	"github.com/LL-res/AOM/log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	// the drivers built in, more can be registered by importing them
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+)
+
+const (
+	defaultTimeout         = 10 * time.Second
+	defaultRefreshInterval = time.Minute
+)
+
+// Database runs sql queries, the query of a metric either returns a single value in one column,
+// stamped with the time it was collected, or time and value columns, e.g. for backfill
+type Database struct {
+	collector.CollectorBase
+	timeout time.Duration
+	driver  string
+	dsn     string
+	// reads the dsn again once refresh passed since loadedAt
+	load     func() (string, error)
+	refresh  time.Duration
+	loadedAt time.Time
+	db       *sql.DB
+	// guards MetricQL, ServerAddress, driver, dsn, load, refresh, loadedAt and db
+	mu sync.RWMutex
+}
+
+func New(timeout time.Duration) collector.Collector {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	res := &Database{timeout: timeout}
+	res.MetricQL = make(map[basetype.Metric]string)
+	return res
+}
+
+// SetServerAddress only records the address, the database is reached through the data source
+func (d *Database) SetServerAddress(url string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.ServerAddress = url
+	return nil
+}
+
+// SetDataSource opens the database, the dsn is read again on the first collect after refresh passed
+// or a query failed, the database is opened anew once it changed
+func (d *Database) SetDataSource(driver string, load func() (string, error), refresh time.Duration) error {
+	if refresh <= 0 {
+		refresh = defaultRefreshInterval
+	}
+	dsn, err := load()
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.load, d.refresh = load, refresh
+	return d.open(driver, dsn)
+}
+
+// open must be called with mu held, the old database is closed once the data source changed
+func (d *Database) open(driver, dsn string) error {
+	d.loadedAt = time.Now()
+	if d.db != nil && driver == d.driver && dsn == d.dsn {
+		return nil
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return err
+	}
+	// a worker collects one query at a time
+	db.SetMaxIdleConns(2)
+	if d.db != nil {
+		if err := d.db.Close(); err != nil {
+			log.Logger.Error(err, "close database failed", "driver", d.driver)
+		}
+	}
+	d.driver, d.dsn, d.db = driver, dsn, db
+	return nil
+}
+
+func (d *Database) getDB() (*sql.DB, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.db == nil {
+		return nil, errors.New("no data source set for the sql collector")
+	}
+	if time.Since(d.loadedAt) < d.refresh {
+		return d.db, nil
+	}
+	dsn, err := d.load()
+	if err == nil {
+		err = d.open(d.driver, dsn)
+	}
+	if err != nil {
+		// the database opened before is kept until the dsn can be read
+		d.loadedAt = time.Now()
+		log.Logger.Error(err, "reload data source failed", "driver", d.driver)
+	}
+	return d.db, nil
+}
+
+// expire reads the dsn again on the next collect, the password may have been rotated
+func (d *Database) expire() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.loadedAt = time.Time{}
+}
+
+func (d *Database) ListMetricTypes() []basetype.Metric {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	result := make([]basetype.Metric, 0, len(d.MetricQL))
+	for m := range d.MetricQL {
+		result = append(result, m)
+	}
+	return result
+}
+
+func (d *Database) AddCustomMetrics(metric basetype.Metric, query string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.MetricQL[metric] = query
+}
+
+func (d *Database) CreateWorker(metric basetype.Metric) (collector.MetricCollector, error) {
+	d.mu.RLock()
+	query, ok := d.MetricQL[metric]
+	d.mu.RUnlock()
+	if !ok {
+		return nil, errors.New("undefined metric type")
+	}
+	return &worker{
+		MetricType: collector.MetricType{
+			Name: metric.Name,
+			Unit: metric.Unit,
+		},
+		key:      metric.NoModelKey(),
+		query:    query,
+		database: d,
+		now:      time.Now,
+	}, nil
+}
+
+type worker struct {
+	collector.MetricType
+	collector.Buffer
+	key      string
+	query    string
+	database *Database
+	now      func() time.Time
+	// the rows of time and value queries not after it were collected already
+	lastTimeStamp time.Time
+}
+
+func (w *worker) Collect() error {
+	db, err := w.database.getDB()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), w.database.timeout)
+	defer cancel()
+	rows, err := db.QueryContext(ctx, w.query)
+	if err != nil {
+		w.database.expire()
+		return err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	var metrics []collector.Metric
+	switch len(columns) {
+	case 1:
+		metrics, err = w.scanValue(rows)
+	case 2:
+		metrics, err = w.scanSeries(rows)
+	default:
+		return fmt.Errorf("query returned %d columns, expect a value or time and value", len(columns))
+	}
+	if err != nil {
+		return err
+	}
+	if len(metrics) == 0 {
+		return errs.EMPTY_RESULT
+	}
+	w.Append(metrics...)
+	return nil
+}
+
+// scanValue takes the value of the first row
+func (w *worker) scanValue(rows *sql.Rows) ([]collector.Metric, error) {
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	var raw interface{}
+	if err := rows.Scan(&raw); err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, nil
+	}
+	value, err := toFloat(raw)
+	if err != nil {
+		return nil, err
+	}
+	return []collector.Metric{{Value: value, TimeStamp: w.now()}}, nil
+}
+
+// scanSeries takes the rows newer than the ones collected before, sorted by time
+func (w *worker) scanSeries(rows *sql.Rows) ([]collector.Metric, error) {
+	var res []collector.Metric
+	for rows.Next() {
+		var rawTime, rawValue interface{}
+		if err := rows.Scan(&rawTime, &rawValue); err != nil {
+			return nil, err
+		}
+		if rawTime == nil || rawValue == nil {
+			continue
+		}
+		ts, err := toTime(rawTime)
+		if err != nil {
+			return nil, err
+		}
+		if !ts.After(w.lastTimeStamp) {
+			continue
+		}
+		value, err := toFloat(rawValue)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, collector.Metric{Value: value, TimeStamp: ts})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].TimeStamp.Before(res[j].TimeStamp)
+	})
+	if len(res) != 0 {
+		w.lastTimeStamp = res[len(res)-1].TimeStamp
+	}
+	return res, nil
+}
+
+func toFloat(raw interface{}) (float64, error) {
+	switch v := raw.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case []byte:
+		return strconv.ParseFloat(strings.TrimSpace(string(v)), 64)
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(v), 64)
+	default:
+		return 0, fmt.Errorf("value %v of type %T is not a number", raw, raw)
+	}
+}
+
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05.999999999", "2006-01-02T15:04:05.999999999"}
+
+// toTime accepts time columns, unix seconds and the text layouts the drivers return
+func toTime(raw interface{}) (time.Time, error) {
+	switch v := raw.(type) {
+	case time.Time:
+		return v, nil
+	case []byte:
+		return toTime(string(v))
+	case string:
+		s := strings.TrimSpace(v)
+		if seconds, err := strconv.ParseFloat(s, 64); err == nil {
+			return toTime(seconds)
+		}
+		for _, layout := range timeLayouts {
+			if ts, err := time.Parse(layout, s); err == nil {
+				return ts, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("invalid time [%s]", s)
+	default:
+		seconds, err := toFloat(raw)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time %v of type %T", raw, raw)
+		}
+		sec, frac := math.Modf(seconds)
+		return time.Unix(int64(sec), int64(frac*1e9)), nil
+	}
+}
+
+func (w *worker) NoModelKey() string {
+	return w.key
+}
@@ -0,0 +1,171 @@
+package sql_collector
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/LL-res/AOM/collector"
+	"github.com/LL-res/AOM/common/basetype"
+	"github.com/LL-res/AOM/common/errs"
+	"github.com/LL-res/AOM/log"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestCollect(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "kpi.db")
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	start := time.Unix(1700000000, 0)
+	for _, stmt := range []string{
+		"CREATE TABLE orders (created_at INTEGER, amount REAL)",
+		"CREATE TABLE stats (name TEXT, value TEXT, at TEXT)",
+		fmt.Sprintf("INSERT INTO orders VALUES (%d, 10), (%d, 20), (%d, 5)", start.Unix(), start.Unix()+60, start.Unix()+120),
+		`INSERT INTO stats VALUES ('ratio', '0.25', '2023-11-14T22:13:20Z'), ('none', NULL, NULL)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := New(time.Second)
+	if err := c.(collector.DataSourceCollector).SetDataSource("sqlite3", func() (string, error) { return dsn, nil }, 0); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		query   string
+		want    []float64
+		wantErr error
+	}{
+		{query: "SELECT count(*) FROM orders", want: []float64{3}},
+		{query: "SELECT sum(amount) FROM orders", want: []float64{35}},
+		{query: "SELECT value FROM stats WHERE name = 'ratio'", want: []float64{0.25}},
+		{query: "SELECT value FROM stats WHERE name = 'none'", wantErr: errs.EMPTY_RESULT},
+		{query: "SELECT value FROM stats WHERE name = 'missing'", wantErr: errs.EMPTY_RESULT},
+		{query: "SELECT created_at, amount FROM orders ORDER BY created_at DESC", want: []float64{10, 20, 5}},
+		{query: "SELECT at, value FROM stats", want: []float64{0.25}},
+		{query: "SELECT name FROM stats WHERE name = 'ratio'", wantErr: errors.New("not a number")},
+		{query: "SELECT name, value, at FROM stats", wantErr: errors.New("3 columns")},
+		{query: "SELECT * FROM missing", wantErr: errors.New("no such table")},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			metric := basetype.Metric{Name: fmt.Sprintf("m%d", i), Query: tt.query}
+			c.AddCustomMetrics(metric, metric.Query)
+			w, err := c.CreateWorker(metric)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = w.Collect()
+			if (err != nil) != (tt.wantErr != nil) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == errs.EMPTY_RESULT && !errors.Is(err, errs.EMPTY_RESULT) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+			got := w.Send()
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for j := range got {
+				if got[j].Value != tt.want[j] {
+					t.Errorf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCollectSeries(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "kpi.db")
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE orders (created_at INTEGER, amount REAL)"); err != nil {
+		t.Fatal(err)
+	}
+	c := New(time.Second)
+	if err := c.(collector.DataSourceCollector).SetDataSource("sqlite3", func() (string, error) { return dsn, nil }, 0); err != nil {
+		t.Fatal(err)
+	}
+	metric := basetype.Metric{Name: "orders", Query: "SELECT created_at, amount FROM orders"}
+	c.AddCustomMetrics(metric, metric.Query)
+	w, err := c.CreateWorker(metric)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := []struct {
+		insert []int64
+		// the timestamps appended after the step
+		want []int64
+	}{
+		{insert: []int64{100, 200}, want: []int64{100, 200}},
+		{want: nil},
+		// rows not newer than the ones collected are not taken again
+		{insert: []int64{150, 300}, want: []int64{300}},
+	}
+	for i, step := range steps {
+		for _, ts := range step.insert {
+			if _, err := db.Exec("INSERT INTO orders VALUES (?, 1)", ts); err != nil {
+				t.Fatal(err)
+			}
+		}
+		err := w.Collect()
+		if len(step.want) == 0 && !errors.Is(err, errs.EMPTY_RESULT) {
+			t.Errorf("step %d: got error %v, want %v", i, err, errs.EMPTY_RESULT)
+		}
+		if len(step.want) != 0 && err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		got := w.Send()
+		if len(got) != len(step.want) {
+			t.Fatalf("step %d: got %v, want %v", i, got, step.want)
+		}
+		for j := range got {
+			if got[j].TimeStamp.Unix() != step.want[j] {
+				t.Errorf("step %d: got %v, want %v", i, got, step.want)
+			}
+		}
+	}
+}
+
+func TestRotate(t *testing.T) {
+	log.Init()
+	dir := t.TempDir()
+	rotated := filepath.Join(dir, "rotated.db")
+	db, err := sql.Open("sqlite3", rotated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE orders (amount REAL)"); err != nil {
+		t.Fatal(err)
+	}
+	dsn := filepath.Join(dir, "old.db")
+	c := New(time.Second)
+	if err := c.(collector.DataSourceCollector).SetDataSource("sqlite3", func() (string, error) { return dsn, nil }, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	metric := basetype.Metric{Name: "orders", Query: "SELECT count(*) FROM orders"}
+	c.AddCustomMetrics(metric, metric.Query)
+	w, err := c.CreateWorker(metric)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the secret is rotated, the failing query reads it again long before the refresh interval
+	dsn = rotated
+	if err := w.Collect(); err == nil {
+		t.Fatal("expected the old data source to fail")
+	}
+	if err := w.Collect(); err != nil {
+		t.Errorf("rotated data source not picked up: %v", err)
+	}
+}
@@ -1,9 +1,35 @@
 package aomtype
 
+import (
+	"github.com/LL-res/AOM/collector"
+	"time"
+)
+
 func (h *Hide) Init() {
 	h.MetricMap.NewConcurrentMap()
 	h.PredictorMap.NewConcurrentMap()
 	h.ModelMap.NewConcurrentMap()
 	h.CollectorWorkerMap.NewConcurrentMap()
+	h.CollectorHealthMap.NewConcurrentMap()
+	h.TrainHistory.NewConcurrentMap()
+	h.TrainingMap.NewConcurrentMap()
 	h.CollectorMap = make(map[string]chan struct{})
+	h.SharedWorkers = make(map[string]*collector.Subscription)
+	h.Queries = make(map[string]string)
+}
+
+// StaleMetrics returns the noModelKeys of the metrics whose latest sample is older than StaleThreshold
+func (h *Hide) StaleMetrics(now time.Time) map[string]struct{} {
+	res := make(map[string]struct{})
+	if h.StaleThreshold <= 0 {
+		return res
+	}
+	h.CollectorHealthMap.RLock()
+	defer h.CollectorHealthMap.RUnlock()
+	for noModelKey, health := range h.CollectorHealthMap.Data {
+		if health.SampleAge(now) > h.StaleThreshold {
+			res[noModelKey] = struct{}{}
+		}
+	}
+	return res
 }
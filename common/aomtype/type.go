@@ -20,13 +20,35 @@ type Hide struct {
 	PredictorMap utils.ConcurrentMap[predictor.Predictor]
 	//noModelKey
 	CollectorWorkerMap utils.ConcurrentMap[collector.MetricCollector]
+	//noModelKey
+	CollectorHealthMap utils.ConcurrentMap[*collector.Health]
+	//noModelKey
+	//the subscriptions to the workers shared with other instances, released instead of closed
+	SharedWorkers map[string]*collector.Subscription
+	//noModelKey
+	//the rendered queries the workers were created with
+	Queries map[string]string
 	//withModelKey
 	ModelMap utils.ConcurrentMap[*basetype.Model]
 	//withModelKey
 	//store the latest timestamp the model trained
 	TrainHistory utils.ConcurrentMap[time.Time]
+	//withModelKey
+	//the trainings of the predictors, run by the scheduler in the background
+	TrainingMap utils.ConcurrentMap[*predictor.Training]
 	//one scaler for one aom instance
 	Scaler *scaler.Scaler
+	//one collector for one aom instance, so instances pointing at different servers do not affect each other
+	Collector collector.Collector
+	// the spec type the Collector was created with
+	CollectorType string
+	// metrics whose latest sample is older than StaleThreshold are stale
+	StaleThreshold time.Duration
+	// how the scheduler treats stale metrics, Block or Ignore
+	StalePolicy string
+	// set once this process created the instance, the maps above are empty until then,
+	// e.g. after a restart although the status tells the instance was synced
+	Created bool
 }
 
 type AOMStore map[types.NamespacedName]*Hide
@@ -3,8 +3,14 @@ package basetype
 import "fmt"
 
 func (m Metric) NoModelKey() string {
+	if m.IsDerived() {
+		return fmt.Sprintf("%s$%s$%s", m.Name, m.Unit, m.Expression)
+	}
 	return fmt.Sprintf("%s$%s$%s", m.Name, m.Unit, m.Query)
 }
+func (m Metric) IsDerived() bool {
+	return m.Kind == MetricKindDerived
+}
 func (m Metric) WithModelKey(modelType string) string {
 	return fmt.Sprintf("%s$%s", m.NoModelKey(), modelType)
 }
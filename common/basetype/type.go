@@ -1,5 +1,12 @@
 package basetype
 
+const (
+	// MetricKindCollected metrics are queried by the collector of the instance
+	MetricKindCollected = "collected"
+	// MetricKindDerived metrics are computed from other metrics of the instance
+	MetricKindDerived = "derived"
+)
+
 type Metric struct {
 	ScaleDownConf ScaleDownConf `json:"scaleDownConf"`
 	Target        string        `json:"target"`
@@ -7,7 +14,21 @@ type Metric struct {
 	Weight int32  `json:"weight"`
 	Name   string `json:"name"`
 	Unit   string `json:"unit"`
-	Query  string `json:"query"`
+	// Query may be a go template, e.g. {{.Namespace}} and {{.TargetName}}, see collector.QueryContext for the variables
+	Query string `json:"query"`
+	// Kind is collected by default
+	// +kubebuilder:validation:Enum=collected;derived
+	// +optional
+	Kind string `json:"kind,omitempty"`
+	// Expression defines a derived metric, it is an arithmetic expression over the keys of
+	// collected metrics in spec.metrics and replicas, the live replica count, e.g. requests / replicas
+	// +optional
+	Expression string `json:"expression,omitempty"`
+	// Quantile of the forecasts the replicas are sized on, e.g. "0.9" trades cost for safety, read off the prediction
+	// intervals of the models giving them, the point forecasts are used by default
+	// +kubebuilder:validation:Pattern=`^0?\.[0-9]+$`
+	// +optional
+	Quantile string `json:"quantile,omitempty"`
 }
 type ScaleDownConf struct {
 	Threshold string `json:"threshold"`
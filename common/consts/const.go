@@ -6,7 +6,9 @@ const (
 )
 
 const (
-	GRU         = "GRU"
-	LSTM        = "LSTM"
-	HOLT_WINTER = "holt_winter"
+	GRU          = "GRU"
+	LSTM         = "LSTM"
+	HOLT_WINTER  = "holt_winter"
+	ARIMA        = "arima"
+	MODEL_SERVER = "model_server"
 )
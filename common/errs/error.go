@@ -5,4 +5,5 @@ import "errors"
 var (
 	NO_SUFFICENT_DATA  = errors.New("no sufficient data")
 	UNREADY_TO_PREDICT = errors.New("the model is not ready to predict")
+	EMPTY_RESULT       = errors.New("the query returned no samples")
 )
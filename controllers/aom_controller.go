@@ -19,20 +19,36 @@ package controllers
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/LL-res/AOM/clients/k8s"
 	"github.com/LL-res/AOM/collector"
+	"github.com/LL-res/AOM/collector/exec_collector"
+	"github.com/LL-res/AOM/collector/file_collector"
+	"github.com/LL-res/AOM/collector/http_collector"
+	"github.com/LL-res/AOM/collector/metrics_api_collector"
+	"github.com/LL-res/AOM/collector/pod_collector"
 	"github.com/LL-res/AOM/collector/prometheus_collector"
+	"github.com/LL-res/AOM/collector/remote_write_collector"
+	"github.com/LL-res/AOM/collector/sql_collector"
+	"github.com/LL-res/AOM/common/aomtype"
 	"github.com/LL-res/AOM/common/basetype"
 	"github.com/LL-res/AOM/common/consts"
+	"github.com/LL-res/AOM/common/errs"
 	"github.com/LL-res/AOM/common/store"
 	"github.com/LL-res/AOM/log"
 	"github.com/LL-res/AOM/predictor"
+	ptype "github.com/LL-res/AOM/predictor/type"
 	"github.com/LL-res/AOM/scheduler"
 	"github.com/LL-res/AOM/utils"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
-	"sync"
+	"sort"
+	"strings"
 	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
@@ -49,20 +65,25 @@ const (
 	metricMapKey            = "metricMap"
 )
 
-var (
-	promcOnce  sync.Once
-	pCollector collector.Collector
-)
-
 // AOMReconciler reconciles a AOM object
 type AOMReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	// optional, checkpoints the collected metrics so they survive restarts
+	History collector.Persister
+	// optional, receives the series of the remote-write collectors
+	RemoteWrite *remote_write_collector.Server
+	// optional, shares the workers of identical metrics across instances
+	Workers *collector.Registry
+	// allows the exec collector, which runs the queries of any AOM as shell commands in the operator's container
+	EnableExecCollector bool
 }
 
 //+kubebuilder:rbac:groups=automation.buaa.io,resources=aoms,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=automation.buaa.io,resources=aoms/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=automation.buaa.io,resources=aoms/finalizers,verbs=update
+//+kubebuilder:rbac:groups=metrics.k8s.io,resources=pods,verbs=get;list
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -82,6 +103,7 @@ func (r *AOMReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 	if err != nil {
 		if k8serrors.IsNotFound(err) {
 			logger.Info("instance deleted")
+			r.releaseWorkers(req.NamespacedName)
 			return reconcile.Result{}, nil
 		}
 		logger.Error(err, "failed to get instance")
@@ -98,13 +120,16 @@ func (r *AOMReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 	// 将这些predictor交给scheduler进行调度
 	// 考虑不同的instance 对应不同的scheduler
 
-	return ctrl.Result{}, nil
+	// requeue to keep the collector health in status up to date
+	return ctrl.Result{RequeueAfter: defaultSyncPeriod}, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *AOMReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&automationv1.AOM{}).
+		// the status is refreshed on a period, its updates need no reconcile
+		WithEventFilter(predicate.GenerationChangedPredicate{}).
 		Complete(r)
 }
 
@@ -137,15 +162,25 @@ func StartWorker(ctx context.Context, worker collector.MetricCollector, aom *aut
 			break
 		default:
 			err := worker.Collect()
-			if err != nil {
+			if err != nil && !errors.Is(err, errs.EMPTY_RESULT) {
 				log.Logger.Error(err, "fail to collect", "worker", worker.NoModelKey())
 			}
+			if health, loadErr := hide.CollectorHealthMap.Load(worker.NoModelKey()); loadErr == nil {
+				var latest collector.Metric
+				var hasLatest bool
+				if l, ok := worker.(collector.LatestCollector); ok {
+					latest, hasLatest = l.Latest()
+				}
+				health.Observe(time.Now(), err, latest, hasLatest)
+			}
 		}
 	}
 }
 
 type Handler struct {
 	instance *automationv1.AOM
+	// built on first use, only needed by templated queries
+	queryCtx *collector.QueryContext
 	//predictors map[*basetype.Metric][]predictor.Predictor
 	*AOMReconciler
 }
@@ -158,23 +193,26 @@ func NewHandler(instance *automationv1.AOM, reconciler *AOMReconciler) *Handler
 }
 
 func (hdlr *Handler) Handle(ctx context.Context) error {
-	// 是由 status的更新导致
-	if hdlr.instance.Status.Generation == hdlr.instance.Generation {
-		return nil
-	}
+	hide := store.GetHide(types.NamespacedName{
+		Namespace: ctx.Value(consts.NAMESPACE).(string),
+		Name:      ctx.Value(consts.NAME).(string),
+	})
 	if err := k8s.NewClient(); err != nil {
 		return err
 	}
+	// 是由 status的更新导致
+	if hide.Created && hdlr.instance.Status.Generation == hdlr.instance.Generation {
+		return hdlr.refreshStatus(ctx)
+	}
 	// 防止过多层if嵌套
 	var err error
-	// create instance
-	if hdlr.instance.Status.Generation == 0 {
+	// create instance, an instance this process has not created yet, e.g. after a restart,
+	// is created again whatever its generation, since the workers and predictors live in memory only
+	if !hide.Created {
 		log.Logger.Info("creating aom instance", "namespace", hdlr.instance.Namespace, "name", hdlr.instance.Name)
 		err = hdlr.handleCreate(ctx)
-	}
-	// update instance
-	if hdlr.instance.Status.Generation != 0 &&
-		hdlr.instance.Generation > hdlr.instance.Status.Generation {
+	} else {
+		// update instance
 		log.Logger.Info("updating aom instance", "namespace", hdlr.instance.Namespace, "name", hdlr.instance.Name)
 		err = hdlr.handleUpdate(ctx)
 	}
@@ -193,6 +231,9 @@ func (hdlr *Handler) Handle(ctx context.Context) error {
 }
 
 func (hdlr *Handler) handleUpdate(ctx context.Context) error {
+	if err := hdlr.handleCollectorServer(ctx); err != nil {
+		return err
+	}
 	if err := hdlr.handleMetrics(ctx); err != nil {
 		return err
 	}
@@ -210,13 +251,7 @@ func (hdlr *Handler) handleUpdate(ctx context.Context) error {
 }
 
 func (hdlr *Handler) handleCreate(ctx context.Context) error {
-	promcOnce.Do(func() {
-		log.Logger.Info("init collector")
-		pCollector = prometheus_collector.New()
-	})
-	err := pCollector.SetServerAddress(hdlr.instance.Spec.Collector.Address)
-	if err != nil {
-		log.Logger.Error(err, "fail to set collector server address")
+	if err := hdlr.handleCollectorServer(ctx); err != nil {
 		return err
 	}
 	hide := store.GetHide(types.NamespacedName{
@@ -248,6 +283,7 @@ func (hdlr *Handler) handleCreate(ctx context.Context) error {
 	}, time.Second*time.Duration(hdlr.instance.Spec.Interval))
 	log.Logger.Info("start scheduler", "scheduler", schdlr)
 	go schdlr.Run(ctx)
+	hide.Created = true
 
 	return nil
 }
@@ -256,6 +292,133 @@ func (hdlr *Handler) handleDelete(ctx context.Context) error {
 	return nil
 }
 
+// handleCollectorServer makes sure the instance owns a collector pointing at the server in spec
+func (hdlr *Handler) handleCollectorServer(ctx context.Context) error {
+	hide := store.GetHide(types.NamespacedName{
+		Namespace: ctx.Value(consts.NAMESPACE).(string),
+		Name:      ctx.Value(consts.NAME).(string),
+	})
+	collectorType := hdlr.instance.Spec.Collector.Type
+	if collectorType == "" {
+		collectorType = automationv1.CollectorTypePrometheus
+	}
+	if hide.Collector != nil && hide.CollectorType != collectorType {
+		// the workers and predictors are bound to the old collector
+		err := fmt.Errorf("collector type can not be changed from %s to %s, recreate the aom instead", hide.CollectorType, collectorType)
+		log.Logger.Error(err, "fail to update collector")
+		return err
+	}
+	if hide.Collector == nil {
+		log.Logger.Info("init collector", "namespace", hdlr.instance.Namespace, "name", hdlr.instance.Name, "type", collectorType)
+		c, err := hdlr.newCollector(collectorType)
+		if err != nil {
+			log.Logger.Error(err, "fail to init collector")
+			return err
+		}
+		hide.Collector = c
+		hide.CollectorType = collectorType
+	}
+	// workers read the client from their collector, so an address change applies to them as well
+	if err := hdlr.configureCollector(hide.Collector); err != nil {
+		return err
+	}
+	hide.StaleThreshold, hide.StalePolicy = staleConf(hdlr.instance.Spec.Collector)
+	return nil
+}
+
+// configureCollector points c at the server in spec with the credentials in spec
+func (hdlr *Handler) configureCollector(c collector.Collector) error {
+	if httpCollector, ok := c.(collector.HTTPCollector); ok {
+		rt, err := newCollectorRoundTripper(hdlr.instance.Namespace, hdlr.instance.Spec.Collector)
+		if err != nil {
+			log.Logger.Error(err, "fail to load collector credentials")
+			return err
+		}
+		// a nil round tripper resets the connection to the default one
+		if rt == nil {
+			err = httpCollector.SetRoundTripper(nil)
+		} else {
+			err = httpCollector.SetRoundTripper(rt)
+		}
+		if err != nil {
+			log.Logger.Error(err, "fail to set collector round tripper")
+			return err
+		}
+	}
+	if dataSourceCollector, ok := c.(collector.DataSourceCollector); ok {
+		conf := hdlr.instance.Spec.Collector.SQL
+		if conf == nil {
+			err := errors.New("the sql collector needs spec.collector.sql")
+			log.Logger.Error(err, "fail to set collector data source")
+			return err
+		}
+		// the secret is read again every refresh interval and after a failed query, so that a rotated password is picked up
+		namespace, ref := hdlr.instance.Namespace, conf.DSN
+		load := func() (string, error) {
+			dsn, err := k8s.GlobalClient.GetSecretKey(namespace, ref)
+			return string(dsn), err
+		}
+		if err := dataSourceCollector.SetDataSource(conf.Driver, load, time.Second*time.Duration(conf.RefreshInterval)); err != nil {
+			log.Logger.Error(err, "fail to set collector data source")
+			return err
+		}
+	}
+	if err := c.SetServerAddress(hdlr.instance.Spec.Collector.Address); err != nil {
+		log.Logger.Error(err, "fail to set collector server address")
+		return err
+	}
+	return nil
+}
+
+func (hdlr *Handler) newCollector(collectorType string) (collector.Collector, error) {
+	switch collectorType {
+	case automationv1.CollectorTypePrometheus:
+		return prometheus_collector.New(), nil
+	case automationv1.CollectorTypeMetricsAPI:
+		return metrics_api_collector.New(hdlr.instance.Namespace, hdlr.instance.Spec.ScaleTargetRef), nil
+	case automationv1.CollectorTypeFile:
+		conf := hdlr.instance.Spec.Collector.File
+		if conf == nil {
+			conf = &automationv1.FileCollector{}
+		}
+		return file_collector.New(conf.Speed, conf.Loop), nil
+	case automationv1.CollectorTypeHTTP:
+		conf := hdlr.instance.Spec.Collector.HTTP
+		if conf == nil {
+			conf = &automationv1.HTTPCollector{}
+		}
+		return http_collector.New(conf.Method, conf.Body, time.Second*time.Duration(conf.Timeout)), nil
+	case automationv1.CollectorTypePods:
+		conf := hdlr.instance.Spec.Collector.Pods
+		if conf == nil {
+			return nil, errors.New("the pods collector needs spec.collector.pods.port")
+		}
+		return pod_collector.New(hdlr.instance.Namespace, hdlr.instance.Spec.ScaleTargetRef, conf.Scheme, conf.Port, conf.Path), nil
+	case automationv1.CollectorTypeSQL:
+		conf := hdlr.instance.Spec.Collector.SQL
+		if conf == nil {
+			return nil, errors.New("the sql collector needs spec.collector.sql")
+		}
+		return sql_collector.New(time.Second * time.Duration(conf.Timeout)), nil
+	case automationv1.CollectorTypeExec:
+		if !hdlr.EnableExecCollector {
+			return nil, errors.New("the exec collector is disabled, start the operator with --enable-exec-collector")
+		}
+		conf := hdlr.instance.Spec.Collector.Exec
+		if conf == nil {
+			conf = &automationv1.ExecCollector{}
+		}
+		return exec_collector.New(time.Second*time.Duration(conf.Timeout), conf.MaxConcurrency), nil
+	case automationv1.CollectorTypeRemoteWrite:
+		if hdlr.RemoteWrite == nil {
+			return nil, errors.New("the remote-write receiver is disabled, start the operator with --remote-write-bind-address")
+		}
+		return hdlr.RemoteWrite.NewReceiver(types.NamespacedName{Namespace: hdlr.instance.Namespace, Name: hdlr.instance.Name}.String()), nil
+	default:
+		return nil, fmt.Errorf("unknown collector type [%s]", collectorType)
+	}
+}
+
 func (hdlr *Handler) handleCollector(ctx context.Context) error {
 	// 此操作为幂等操作
 	// 其中的元素是格式化之后的metric，格式为: name$unit$query
@@ -284,38 +447,122 @@ func (hdlr *Handler) handleCollector(ctx context.Context) error {
 			toDelete = append(toDelete, k)
 		}
 	}
+	// a shared worker is bound to the address, rendered query and interval it was acquired with,
+	// so it is acquired again once they change, along with the derived metrics reading it
+	rekeyed := make([]basetype.Metric, 0)
+	for k, sub := range hide.SharedWorkers {
+		metric, ok := hdlr.specMetric(k)
+		if !ok {
+			continue
+		}
+		query, err := hdlr.renderQuery(metric.Query)
+		if err != nil {
+			log.Logger.Error(err, "fail to render metric query", "metric key", k)
+			return err
+		}
+		key, err := hdlr.workerKey(hide.CollectorType, query)
+		if err != nil {
+			return err
+		}
+		if key != sub.Key() {
+			rekeyed = append(rekeyed, metric)
+		}
+	}
+	if len(rekeyed) != 0 {
+		for _, metric := range hdlr.instance.Spec.Metrics {
+			if _, ok := hide.CollectorMap[metric.NoModelKey()]; ok && metric.IsDerived() {
+				rekeyed = append(rekeyed, metric)
+			}
+		}
+	}
+	for _, m := range rekeyed {
+		log.Logger.Info("recreate metric worker", "metric", m.NoModelKey())
+		hdlr.stopWorker(hide, m.NoModelKey())
+		toAdd = append(toAdd, m)
+	}
 	for _, v := range toDelete {
 		log.Logger.Info("delete metric worker", "metric", v)
 		// 对collecter worker进行退出控制
-		close(hide.CollectorMap[v])
-		hide.CollectorWorkerMap.Delete(v)
+		hdlr.stopWorker(hide, v)
+		if hdlr.History != nil {
+			if err := collector.DeleteCheckpoint(hdlr.History, historyKey(hdlr.instance, v)); err != nil {
+				log.Logger.Error(err, "fail to delete metric checkpoint", "metric", v)
+			}
+		}
 	}
+	// derived metrics read the workers of collected metrics, so those are created first
+	sort.SliceStable(toAdd, func(i, j int) bool {
+		return !toAdd[i].IsDerived() && toAdd[j].IsDerived()
+	})
 	for _, m := range toAdd {
-		pCollector.AddCustomMetrics(m)
-		worker, err := pCollector.CreateWorker(m)
-		if err != nil {
-			log.Logger.Error(err, "fail to create metric collector worker")
-			return err
+		var worker collector.MetricCollector
+		// what the worker runs, shown in status
+		query := m.Expression
+		if m.IsDerived() {
+			derived, err := hdlr.newDerivedWorker(hide, m)
+			if err != nil {
+				log.Logger.Error(err, "fail to create derived metric worker")
+				return err
+			}
+			worker = derived
+		} else {
+			var err error
+			query, err = hdlr.renderQuery(m.Query)
+			if err != nil {
+				log.Logger.Error(err, "fail to render metric query", "metric key", m.NoModelKey())
+				return err
+			}
+			if hdlr.Workers != nil && shareable(hide.CollectorType) {
+				key, err := hdlr.workerKey(hide.CollectorType, query)
+				if err != nil {
+					return err
+				}
+				sub, err := hdlr.newSharedWorker(hide.CollectorType, key, m)
+				if err != nil {
+					log.Logger.Error(err, "fail to create metric collector worker")
+					return err
+				}
+				hide.SharedWorkers[m.NoModelKey()] = sub
+				worker = sub
+			} else {
+				hide.Collector.AddCustomMetrics(m, query)
+				collected, err := hide.Collector.CreateWorker(m)
+				if err != nil {
+					log.Logger.Error(err, "fail to create metric collector worker")
+					return err
+				}
+				worker = collected
+			}
+		}
+		if persistent, ok := worker.(collector.PersistentCollector); ok && hdlr.History != nil {
+			if err := persistent.Persist(hdlr.History, historyKey(hdlr.instance, m.NoModelKey())); err != nil {
+				log.Logger.Error(err, "fail to restore metric checkpoint")
+				return err
+			}
 		}
 		log.Logger.Info("create metric worker", "metric key", m.NoModelKey())
 		hide.CollectorWorkerMap.Store(m.NoModelKey(), worker)
+		hide.Queries[m.NoModelKey()] = query
 		stopC := make(chan struct{})
 		hide.CollectorMap[m.NoModelKey()] = stopC
+		if sub, ok := worker.(*collector.Subscription); ok {
+			// the registry collects it, the stop channel only marks the metric as handled
+			hide.CollectorHealthMap.Store(m.NoModelKey(), sub.Health())
+			continue
+		}
+		hide.CollectorHealthMap.Store(m.NoModelKey(), collector.NewHealth(time.Now()))
 		go StartWorker(ctx, worker, hdlr.instance, stopC)
 	}
 	// 更新status
-	hdlr.instance.Status.StatusCollectors = make([]automationv1.StatusCollector, 0, len(hdlr.instance.Spec.Metrics))
-	for _, metric := range hdlr.instance.Spec.Metrics {
-		// 此处仅作describe时显示
-		hdlr.instance.Status.StatusCollectors = append(hdlr.instance.Status.StatusCollectors, automationv1.StatusCollector{
-			Name:       metric.Name,
-			Unit:       metric.Unit,
-			Expression: metric.Query,
-		})
-	}
+	hdlr.updateCollectorStatus(hide, time.Now())
 	return nil
 }
 
+// historyKey tells apart the same metric used by different instances
+func historyKey(instance *automationv1.AOM, noModelKey string) string {
+	return fmt.Sprintf("%s/%s/%s", instance.Namespace, instance.Name, noModelKey)
+}
+
 type mdlMtrc struct {
 	basetype.Model
 	basetype.Metric
@@ -327,6 +574,13 @@ func (hdlr *Handler) handlePredictor(ctx context.Context, changeMap map[string]b
 		Name:      ctx.Value(consts.NAME).(string),
 	})
 	// 扫一遍spec 查看现在所需的
+	// unknown attributes were ignored before the schemas, they are only reported
+	_, warnings := predictor.ValidateModels(hdlr.instance.Spec.Models, field.NewPath("spec", "models"))
+	for _, warning := range warnings {
+		log.Logger.Info("ignore unknown model attribute", "warning", warning.Error())
+	}
+	// an invalid model is skipped, the other models of the instance keep predicting
+	invalid := make(map[string]error)
 
 	// sepc 中存在，map中不存在
 	toAdd := make([]mdlMtrc, 0)
@@ -370,7 +624,7 @@ func (hdlr *Handler) handlePredictor(ctx context.Context, changeMap map[string]b
 	}
 	for _, wmk := range toDelete {
 		log.Logger.Info("delete predictor", "predictor", wmk)
-		hide.PredictorMap.Delete(wmk)
+		removePredictor(hide, wmk)
 		//nmk := utils.GetNoModelKey(wmk)
 		//找到metric对应的那一组predictor
 		//metric, err := hdlr.instance.MetricMap.Get(nmk)
@@ -400,10 +654,12 @@ func (hdlr *Handler) handlePredictor(ctx context.Context, changeMap map[string]b
 		}
 		pred, err := predictor.NewPredictor(pm)
 		if err != nil {
-			log.Logger.Error(err, "new predictor failed", "param", pm)
-			return err
+			log.Logger.Error(err, "new predictor failed, skip the model", "param", pm)
+			invalid[WithModelKey] = err
+			continue
 		}
 		hide.PredictorMap.Store(param.WithModelKey(param.Type), pred)
+		storeTraining(hide, param.WithModelKey(param.Type))
 		//metric, err := hdlr.instance.MetricMap.Get(param.NoModelKey())
 		//if err != nil {
 		//	log.Logger.Error(err, "a must behaviour failed,predictor can not find the corresponding metric")
@@ -424,15 +680,70 @@ func (hdlr *Handler) handlePredictor(ctx context.Context, changeMap map[string]b
 			ScaleTargetRef:  hdlr.instance.Spec.ScaleTargetRef,
 		})
 		if err != nil {
-			log.Logger.Error(err, "new predictor failed")
-			return err
+			// the predictor of the old model no longer matches spec
+			log.Logger.Error(err, "new predictor failed, skip the model", "predictor", wmk)
+			removePredictor(hide, wmk)
+			invalid[wmk] = err
+			continue
 		}
 		hide.PredictorMap.Store(wmk, pred)
+		storeTraining(hide, wmk)
 	}
-	// TODO STATUS
+	hdlr.setModelsCondition(invalid, warnings)
+	hdlr.updatePredictorStatus(hide)
 	return nil
 }
 
+// removePredictor drops a predictor with its trainings
+func removePredictor(hide *aomtype.Hide, withModelKey string) {
+	if pred, err := hide.PredictorMap.Load(withModelKey); err == nil {
+		if removable, ok := pred.(ptype.Removable); ok {
+			removable.Remove()
+		}
+	}
+	hide.PredictorMap.Delete(withModelKey)
+	hide.TrainingMap.Delete(withModelKey)
+	hide.TrainHistory.Delete(withModelKey)
+}
+
+// storeTraining tracks the trainings of a new predictor, the one of a replaced predictor is kept
+// so that the new one does not train while the old one still does
+func storeTraining(hide *aomtype.Hide, withModelKey string) {
+	if _, err := hide.TrainingMap.Load(withModelKey); err != nil {
+		hide.TrainingMap.Store(withModelKey, predictor.NewTraining())
+	}
+}
+
+// setModelsCondition reports the models skipped as invalid and the attributes ignored as unknown
+func (hdlr *Handler) setModelsCondition(invalid map[string]error, warnings field.ErrorList) {
+	cond := metav1.Condition{
+		Type:               automationv1.ConditionModelsValid,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: hdlr.instance.Generation,
+		Reason:             "Valid",
+		Message:            "all models are valid",
+	}
+	if len(warnings) != 0 {
+		cond.Reason = "UnknownAttributes"
+		cond.Message = fmt.Sprintf("unknown attributes ignored: %s", warnings.ToAggregate())
+	}
+	if len(invalid) != 0 {
+		keys := make([]string, 0, len(invalid))
+		for k := range invalid {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		msgs := make([]string, 0, len(keys))
+		for _, k := range keys {
+			msgs = append(msgs, fmt.Sprintf("%s: %s", k, invalid[k]))
+		}
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = "InvalidModels"
+		cond.Message = fmt.Sprintf("models skipped: %s", strings.Join(msgs, "; "))
+	}
+	meta.SetStatusCondition(&hdlr.instance.Status.Conditions, cond)
+}
+
 func (hdlr *Handler) handleMetrics(ctx context.Context) error {
 	hide := store.GetHide(types.NamespacedName{
 		Namespace: ctx.Value(consts.NAMESPACE).(string),
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"errors"
+	automationv1 "github.com/LL-res/AOM/api/v1"
+	"github.com/LL-res/AOM/clients/httpauth"
+	"github.com/LL-res/AOM/clients/k8s"
+	"time"
+)
+
+//+kubebuilder:rbac:groups="",resources=secrets;configmaps,verbs=get
+
+// newCollectorRoundTripper returns nil when the collector spec needs neither auth nor extra headers
+func newCollectorRoundTripper(namespace string, spec automationv1.Collector) (*httpauth.RoundTripper, error) {
+	if spec.Auth == nil && len(spec.Headers) == 0 {
+		return nil, nil
+	}
+	refresh := time.Duration(0)
+	if spec.Auth != nil {
+		refresh = time.Second * time.Duration(spec.Auth.RefreshInterval)
+	}
+	return httpauth.NewRoundTripper(httpAuthLoader(namespace, *spec.DeepCopy()), refresh)
+}
+
+// httpAuthLoader reads the secrets and configmaps referenced by the collector spec every time it is called
+func httpAuthLoader(namespace string, spec automationv1.Collector) httpauth.Loader {
+	return func() (httpauth.Config, error) {
+		conf := httpauth.Config{
+			Headers: spec.Headers,
+		}
+		auth := spec.Auth
+		if auth == nil {
+			return conf, nil
+		}
+		if auth.BearerToken != nil {
+			token, err := k8s.GlobalClient.GetSecretKey(namespace, *auth.BearerToken)
+			if err != nil {
+				return conf, err
+			}
+			conf.BearerToken = string(token)
+		}
+		if auth.BasicAuth != nil {
+			username, err := k8s.GlobalClient.GetSecretKey(namespace, auth.BasicAuth.Username)
+			if err != nil {
+				return conf, err
+			}
+			password, err := k8s.GlobalClient.GetSecretKey(namespace, auth.BasicAuth.Password)
+			if err != nil {
+				return conf, err
+			}
+			conf.Username = string(username)
+			conf.Password = string(password)
+		}
+		if auth.TLS == nil {
+			return conf, nil
+		}
+		conf.ServerName = auth.TLS.ServerName
+		conf.InsecureSkipVerify = auth.TLS.InsecureSkipVerify
+		var err error
+		if conf.CA, err = getSecretOrConfigMap(namespace, auth.TLS.CA); err != nil {
+			return conf, err
+		}
+		if conf.Cert, err = getSecretOrConfigMap(namespace, auth.TLS.Cert); err != nil {
+			return conf, err
+		}
+		if auth.TLS.KeySecret != nil {
+			if conf.Key, err = k8s.GlobalClient.GetSecretKey(namespace, *auth.TLS.KeySecret); err != nil {
+				return conf, err
+			}
+		}
+		return conf, nil
+	}
+}
+
+func getSecretOrConfigMap(namespace string, ref *automationv1.SecretOrConfigMap) ([]byte, error) {
+	if ref == nil {
+		return nil, nil
+	}
+	if ref.Secret != nil && ref.ConfigMap != nil {
+		return nil, errors.New("only one of secret and configMap should be set")
+	}
+	if ref.Secret != nil {
+		return k8s.GlobalClient.GetSecretKey(namespace, *ref.Secret)
+	}
+	if ref.ConfigMap != nil {
+		return k8s.GlobalClient.GetConfigMapKey(namespace, *ref.ConfigMap)
+	}
+	return nil, nil
+}
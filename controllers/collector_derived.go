@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/LL-res/AOM/clients/k8s"
+	"github.com/LL-res/AOM/collector"
+	"github.com/LL-res/AOM/collector/derived_collector"
+	"github.com/LL-res/AOM/common/aomtype"
+	"github.com/LL-res/AOM/common/basetype"
+	"time"
+)
+
+// newDerivedWorker resolves the variables of a derived metric to the workers of the collected metrics
+// in spec.metrics, the collected workers must have been created before
+func (hdlr *Handler) newDerivedWorker(hide *aomtype.Hide, metric basetype.Metric) (collector.MetricCollector, error) {
+	expr, err := derived_collector.Parse(metric.Expression)
+	if err != nil {
+		return nil, fmt.Errorf("derived metric [%s]: %w", metric.Name, err)
+	}
+	sources := make(map[string]collector.MetricCollector)
+	for _, v := range expr.Vars() {
+		if v == derived_collector.Replicas {
+			continue
+		}
+		source, ok := hdlr.instance.Spec.Metrics[v]
+		if !ok {
+			return nil, fmt.Errorf("derived metric [%s] refers to [%s] which is not in spec.metrics", metric.Name, v)
+		}
+		if source.IsDerived() {
+			return nil, fmt.Errorf("derived metric [%s] refers to derived metric [%s]", metric.Name, v)
+		}
+		worker, err := hide.CollectorWorkerMap.Load(source.NoModelKey())
+		if err != nil {
+			return nil, err
+		}
+		sources[v] = worker
+	}
+	namespace, scaleTargetRef := hdlr.instance.Namespace, hdlr.instance.Spec.ScaleTargetRef
+	replicas := func() (int32, error) {
+		return k8s.GlobalClient.GetReplica(namespace, scaleTargetRef)
+	}
+	// sources scraped on the same tick may still be up to one interval apart
+	tolerance := 2 * time.Second * time.Duration(hdlr.instance.Spec.Collector.ScrapeInterval)
+	return derived_collector.NewWorker(metric, sources, replicas, tolerance)
+}
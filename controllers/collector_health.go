@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	automationv1 "github.com/LL-res/AOM/api/v1"
+	"github.com/LL-res/AOM/common/aomtype"
+	"github.com/LL-res/AOM/common/consts"
+	"github.com/LL-res/AOM/common/store"
+	"github.com/LL-res/AOM/log"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// a worker failing or returning nothing this many times in a row is unhealthy
+	unhealthyThreshold = 3
+	// the default stale threshold in scrape intervals
+	defaultStaleIntervals = 10
+)
+
+func staleConf(conf automationv1.Collector) (time.Duration, string) {
+	threshold := time.Second * time.Duration(conf.StaleThreshold)
+	if threshold == 0 {
+		threshold = defaultStaleIntervals * time.Second * time.Duration(conf.ScrapeInterval)
+	}
+	policy := conf.StalePolicy
+	if policy == "" {
+		policy = automationv1.StalePolicyBlock
+	}
+	return threshold, policy
+}
+
+// refreshStatus writes the latest collector health and trainings to status, nothing is written if it did not change
+func (hdlr *Handler) refreshStatus(ctx context.Context) error {
+	hide := store.GetHide(types.NamespacedName{
+		Namespace: ctx.Value(consts.NAMESPACE).(string),
+		Name:      ctx.Value(consts.NAME).(string),
+	})
+	old := hdlr.instance.Status.DeepCopy()
+	hdlr.updateCollectorStatus(hide, time.Now())
+	hdlr.updatePredictorStatus(hide)
+	if equality.Semantic.DeepEqual(*old, hdlr.instance.Status) {
+		return nil
+	}
+	if err := hdlr.Status().Update(ctx, hdlr.instance); err != nil {
+		log.Logger.Error(err, "update status failed")
+		return err
+	}
+	return nil
+}
+
+// updateCollectorStatus fills in the collectors of status with their health and rolls it up into conditions
+func (hdlr *Handler) updateCollectorStatus(hide *aomtype.Hide, now time.Time) {
+	keys := make([]string, 0, len(hdlr.instance.Spec.Metrics))
+	for k := range hdlr.instance.Spec.Metrics {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	stale := hide.StaleMetrics(now)
+	var unhealthy, staleNames []string
+	collectors := make([]automationv1.StatusCollector, 0, len(keys))
+	for _, k := range keys {
+		metric := hdlr.instance.Spec.Metrics[k]
+		// 此处仅作describe时显示
+		// the query rendered when the worker was created, the one in spec until then
+		query, ok := hide.Queries[metric.NoModelKey()]
+		if !ok {
+			query = metric.Query
+			if metric.IsDerived() {
+				query = metric.Expression
+			}
+		}
+		status := automationv1.StatusCollector{
+			Name:       metric.Name,
+			Unit:       metric.Unit,
+			Expression: query,
+		}
+		if health, err := hide.CollectorHealthMap.Load(metric.NoModelKey()); err == nil {
+			snapshot := health.Snapshot()
+			if !snapshot.LastSuccess.IsZero() {
+				status.LastSuccess = &metav1.Time{Time: snapshot.LastSuccess}
+			}
+			if !snapshot.LastSample.IsZero() {
+				status.LastSample = &metav1.Time{Time: snapshot.LastSample}
+			}
+			status.ConsecutiveFailures = snapshot.ConsecutiveFailures
+			status.ConsecutiveEmpty = snapshot.ConsecutiveEmpty
+			status.ErrorRate = snapshot.ErrorRate
+			status.LastError = snapshot.LastError
+			if snapshot.ConsecutiveFailures >= unhealthyThreshold || snapshot.ConsecutiveEmpty >= unhealthyThreshold {
+				unhealthy = append(unhealthy, k)
+			}
+		} else {
+			// no worker collects the metric
+			unhealthy = append(unhealthy, k)
+		}
+		if _, ok := stale[metric.NoModelKey()]; ok {
+			status.Stale = true
+			staleNames = append(staleNames, k)
+		}
+		collectors = append(collectors, status)
+	}
+	hdlr.instance.Status.StatusCollectors = collectors
+
+	healthy := metav1.Condition{
+		Type:               automationv1.ConditionCollectorsHealthy,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: hdlr.instance.Generation,
+		Reason:             "Collecting",
+		Message:            "all metrics are collected",
+	}
+	if len(unhealthy) != 0 {
+		healthy.Status = metav1.ConditionFalse
+		healthy.Reason = "CollectFailed"
+		healthy.Message = fmt.Sprintf("metrics failing, returning no samples or not collected: %s", strings.Join(unhealthy, ", "))
+	}
+	meta.SetStatusCondition(&hdlr.instance.Status.Conditions, healthy)
+
+	fresh := metav1.Condition{
+		Type:               automationv1.ConditionMetricsFresh,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: hdlr.instance.Generation,
+		Reason:             "Fresh",
+		Message:            fmt.Sprintf("all samples are newer than %s", hide.StaleThreshold),
+	}
+	if len(staleNames) != 0 {
+		fresh.Status = metav1.ConditionFalse
+		fresh.Reason = "Stale"
+		fresh.Message = fmt.Sprintf("no sample newer than %s for %s, stale policy %s",
+			hide.StaleThreshold, strings.Join(staleNames, ", "), hide.StalePolicy)
+	}
+	meta.SetStatusCondition(&hdlr.instance.Status.Conditions, fresh)
+}
@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/LL-res/AOM/clients/k8s"
+	"github.com/LL-res/AOM/collector"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"time"
+)
+
+// renderQuery renders the templated metric queries with the instance and its scale target
+func (hdlr *Handler) renderQuery(query string) (string, error) {
+	if !collector.IsTemplate(query) {
+		return query, nil
+	}
+	if hdlr.queryCtx == nil {
+		queryCtx, err := hdlr.newQueryContext()
+		if err != nil {
+			return "", err
+		}
+		hdlr.queryCtx = queryCtx
+	}
+	rendered, err := collector.RenderQuery(query, *hdlr.queryCtx)
+	if err != nil {
+		return "", fmt.Errorf("render query [%s] failed: %w", query, err)
+	}
+	return rendered, nil
+}
+
+func (hdlr *Handler) newQueryContext() (*collector.QueryContext, error) {
+	spec := hdlr.instance.Spec
+	selector, err := k8s.GlobalClient.GetSelector(hdlr.instance.Namespace, spec.ScaleTargetRef)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := labels.Parse(selector)
+	if err != nil {
+		return nil, err
+	}
+	requirements, _ := parsed.Requirements()
+	selectorLabels := make(map[string]string)
+	for _, r := range requirements {
+		op := r.Operator()
+		if (op == selection.Equals || op == selection.DoubleEquals || op == selection.In) && r.Values().Len() == 1 {
+			selectorLabels[r.Key()] = r.Values().List()[0]
+		}
+	}
+	return &collector.QueryContext{
+		Namespace:      hdlr.instance.Namespace,
+		TargetName:     spec.ScaleTargetRef.Name,
+		TargetKind:     spec.ScaleTargetRef.Kind,
+		Selector:       selector,
+		SelectorLabels: selectorLabels,
+		ScrapeInterval: collector.PromDuration(time.Second * time.Duration(spec.Collector.ScrapeInterval)),
+	}, nil
+}
@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	automationv1 "github.com/LL-res/AOM/api/v1"
+	"github.com/LL-res/AOM/collector"
+	"github.com/LL-res/AOM/common/aomtype"
+	"github.com/LL-res/AOM/common/basetype"
+	"github.com/LL-res/AOM/common/store"
+	"github.com/LL-res/AOM/log"
+	"k8s.io/apimachinery/pkg/types"
+	"time"
+)
+
+// shareable tells whether the workers of a collector type can be shared with other instances,
+// the remote-write receivers are bound to the instance the series are pushed for
+func shareable(collectorType string) bool {
+	return collectorType != automationv1.CollectorTypeRemoteWrite
+}
+
+// workerKey identifies the worker collecting query for the instance, instances with equal keys share the worker
+func (hdlr *Handler) workerKey(collectorType, query string) (collector.WorkerKey, error) {
+	spec := hdlr.instance.Spec.Collector
+	scope := struct {
+		Type string `json:"type"`
+		// the secrets are looked up in the namespace of the instance
+		Namespace string `json:"namespace,omitempty"`
+		// the scale target the pods are looked up for
+		ScaleTargetRef string                      `json:"scaleTargetRef,omitempty"`
+		Auth           *automationv1.CollectorAuth `json:"auth,omitempty"`
+		Headers        map[string]string           `json:"headers,omitempty"`
+		File           *automationv1.FileCollector `json:"file,omitempty"`
+		HTTP           *automationv1.HTTPCollector `json:"http,omitempty"`
+		Pods           *automationv1.PodsCollector `json:"pods,omitempty"`
+		SQL            *automationv1.SQLCollector  `json:"sql,omitempty"`
+		Exec           *automationv1.ExecCollector `json:"exec,omitempty"`
+	}{
+		Type:    collectorType,
+		Auth:    spec.Auth,
+		Headers: spec.Headers,
+		File:    spec.File,
+		HTTP:    spec.HTTP,
+		Pods:    spec.Pods,
+		SQL:     spec.SQL,
+		Exec:    spec.Exec,
+	}
+	if spec.Auth != nil || spec.SQL != nil {
+		scope.Namespace = hdlr.instance.Namespace
+	}
+	if collectorType == automationv1.CollectorTypeMetricsAPI || collectorType == automationv1.CollectorTypePods {
+		ref := hdlr.instance.Spec.ScaleTargetRef
+		scope.Namespace = hdlr.instance.Namespace
+		scope.ScaleTargetRef = fmt.Sprintf("%s/%s/%s", ref.APIVersion, ref.Kind, ref.Name)
+	}
+	raw, err := json.Marshal(scope)
+	if err != nil {
+		return collector.WorkerKey{}, err
+	}
+	return collector.WorkerKey{
+		Address:  spec.Address,
+		Query:    query,
+		Interval: time.Second * time.Duration(spec.ScrapeInterval),
+		Scope:    string(raw),
+	}, nil
+}
+
+// specMetric finds the metric of a noModelKey in spec
+func (hdlr *Handler) specMetric(noModelKey string) (basetype.Metric, bool) {
+	for _, metric := range hdlr.instance.Spec.Metrics {
+		if metric.NoModelKey() == noModelKey {
+			return metric, true
+		}
+	}
+	return basetype.Metric{}, false
+}
+
+// newSharedWorker subscribes to the worker collecting metric, the worker gets a collector of its own,
+// so that a change of this instance's collector does not affect the other subscribers
+func (hdlr *Handler) newSharedWorker(collectorType string, key collector.WorkerKey, metric basetype.Metric) (*collector.Subscription, error) {
+	return hdlr.Workers.Acquire(key, metric.NoModelKey(), func() (collector.MetricCollector, error) {
+		c, err := hdlr.newCollector(collectorType)
+		if err != nil {
+			return nil, err
+		}
+		if err := hdlr.configureCollector(c); err != nil {
+			return nil, err
+		}
+		c.AddCustomMetrics(metric, key.Query)
+		return c.CreateWorker(metric)
+	})
+}
+
+// stopWorker stops the worker of the metric, a shared worker only once no other instance subscribes to it
+func (r *AOMReconciler) stopWorker(hide *aomtype.Hide, noModelKey string) {
+	if stopC, ok := hide.CollectorMap[noModelKey]; ok {
+		close(stopC)
+		delete(hide.CollectorMap, noModelKey)
+	}
+	if sub, ok := hide.SharedWorkers[noModelKey]; ok {
+		r.Workers.Release(sub)
+		delete(hide.SharedWorkers, noModelKey)
+	} else if removable, ok := hide.Collector.(collector.RemovableCollector); ok {
+		removable.RemoveWorker(noModelKey)
+	}
+	delete(hide.Queries, noModelKey)
+	hide.CollectorWorkerMap.Delete(noModelKey)
+	hide.CollectorHealthMap.Delete(noModelKey)
+}
+
+// releaseWorkers stops the workers of a deleted instance and drops its predictors
+func (r *AOMReconciler) releaseWorkers(name types.NamespacedName) {
+	hide := store.GetHide(name)
+	hide.PredictorMap.RLock()
+	withModelKeys := make([]string, 0, len(hide.PredictorMap.Data))
+	for withModelKey := range hide.PredictorMap.Data {
+		withModelKeys = append(withModelKeys, withModelKey)
+	}
+	hide.PredictorMap.RUnlock()
+	for _, withModelKey := range withModelKeys {
+		log.Logger.Info("delete predictor", "predictor", withModelKey)
+		removePredictor(hide, withModelKey)
+	}
+	for noModelKey := range hide.CollectorMap {
+		log.Logger.Info("delete metric worker", "metric", noModelKey)
+		r.stopWorker(hide, noModelKey)
+	}
+	if r.RemoteWrite != nil && hide.CollectorType == automationv1.CollectorTypeRemoteWrite {
+		r.RemoteWrite.RemoveReceiver(name.String())
+	}
+	// an instance created again under the name may use another collector type
+	hide.Collector, hide.CollectorType = nil, ""
+	hide.Created = false
+}
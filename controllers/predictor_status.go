@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	automationv1 "github.com/LL-res/AOM/api/v1"
+	"github.com/LL-res/AOM/common/aomtype"
+	ptype "github.com/LL-res/AOM/predictor/type"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sort"
+	"time"
+)
+
+// updatePredictorStatus fills in the predictors of status with their trainings and the details they report
+func (hdlr *Handler) updatePredictorStatus(hide *aomtype.Hide) {
+	keys := make([]string, 0, len(hdlr.instance.Spec.Models))
+	for k := range hdlr.instance.Spec.Models {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var predictors []automationv1.StatusPredictor
+	for _, k := range keys {
+		metric, ok := hdlr.instance.Spec.Metrics[k]
+		if !ok {
+			continue
+		}
+		for _, model := range hdlr.instance.Spec.Models[k] {
+			withModelKey := metric.WithModelKey(model.Type)
+			// the models skipped as invalid have no predictor
+			pred, err := hide.PredictorMap.Load(withModelKey)
+			if err != nil {
+				continue
+			}
+			status := automationv1.StatusPredictor{
+				Metric: k,
+				Type:   model.Type,
+			}
+			if training, err := hide.TrainingMap.Load(withModelKey); err == nil {
+				snapshot := training.Snapshot()
+				status.Training = snapshot.Running
+				if !snapshot.LastTrain.IsZero() {
+					// to the precision kept by the api server, so that an unchanged status is not written again
+					status.LastTrain = &metav1.Time{Time: snapshot.LastTrain.Truncate(time.Second)}
+				}
+				status.LastTrainError = snapshot.LastError
+			}
+			if reporter, ok := pred.(ptype.Reporter); ok {
+				status.Details = reporter.Report()
+			}
+			predictors = append(predictors, status)
+		}
+	}
+	hdlr.instance.Status.StatusPredictors = predictors
+}
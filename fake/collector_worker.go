@@ -35,6 +35,13 @@ func (c *CollectorWorker) DataCap() int {
 	return 1<<32 - 1
 }
 
+// Window rolls the generated points up the same way the history of real workers does
+func (c *CollectorWorker) Window(resolution time.Duration, n int) ([]collector.Metric, error) {
+	history := collector.NewHistory(collector.Retention{})
+	history.Add(c.Send()...)
+	return history.Window(resolution, n)
+}
+
 func (c *CollectorWorker) Collect() error {
 	return nil
 }
@@ -18,8 +18,15 @@ package main
 
 import (
 	"flag"
+	"github.com/LL-res/AOM/collector"
+	"github.com/LL-res/AOM/collector/checkpoint"
+	"github.com/LL-res/AOM/collector/remote_write_collector"
 	"github.com/LL-res/AOM/log"
+	"github.com/LL-res/AOM/predictor/GRU"
+	"github.com/LL-res/AOM/predictor/supervisor"
 	"os"
+	"path/filepath"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -51,11 +58,42 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var historyDir string
+	var historyOpts checkpoint.Options
+	var remoteWriteAddr string
+	var enableExecCollector bool
+	var modelServerPython, modelServerScript, modelServerDir string
+	var modelServerOpts supervisor.Config
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&historyDir, "history-dir", "", "The directory the collected metrics are checkpointed to, "+
+		"e.g. a mounted PVC. Leave it empty to keep the metrics in memory only.")
+	flag.DurationVar(&historyOpts.Retention, "history-retention", 0, "Checkpointed metrics older than this are dropped, "+
+		"0 keeps them as long as the window they belong to, see --window-*-retention.")
+	flag.IntVar(&historyOpts.MaxPoints, "history-max-points", 0, "The max number of checkpointed metrics kept for each metric, 0 keeps everything.")
+	flag.IntVar(&historyOpts.CompactThreshold, "history-compact-threshold", 1000, "A checkpoint file is compacted once it holds this many stale records.")
+	flag.DurationVar(&collector.HistoryRetention.Raw, "window-raw-retention", collector.HistoryRetention.Raw,
+		"How long the raw metrics are kept in memory for the models, and the ones not sent to the GRU and LSTM yet, 0 keeps everything.")
+	flag.DurationVar(&collector.HistoryRetention.Minute, "window-1m-retention", collector.HistoryRetention.Minute,
+		"How long the 1m rollups of the metrics are kept in memory for the models, 0 keeps everything.")
+	flag.DurationVar(&collector.HistoryRetention.TenMinutes, "window-10m-retention", collector.HistoryRetention.TenMinutes,
+		"How long the 10m rollups of the metrics are kept in memory for the models, 0 keeps everything.")
+	flag.StringVar(&remoteWriteAddr, "remote-write-bind-address", "", "The address the prometheus remote-write endpoint binds to, "+
+		"the series pushed to it feed the remote-write collectors. Leave it empty to disable the endpoint.")
+	flag.BoolVar(&enableExecCollector, "enable-exec-collector", false, "Allow the exec collector, which runs the queries of the AOMs "+
+		"as shell commands in the operator's container, so anyone able to create an AOM can run commands with its service account.")
+	flag.StringVar(&modelServerScript, "model-server-script", "", "The main.py of the python model server the GRU and LSTM models "+
+		"are served by, it is run and supervised by the operator. Leave it empty to run the model server apart.")
+	flag.StringVar(&modelServerPython, "model-server-python", "python3", "The python interpreter running the model server.")
+	flag.StringVar(&modelServerDir, "model-server-dir", "", "The working directory of the model server, where it keeps the models, "+
+		"the directory of the script by default.")
+	flag.StringVar(&modelServerOpts.Socket, "model-server-socket", GRU.PySocket, "The unix socket the model server listens on.")
+	flag.DurationVar(&modelServerOpts.ReadyTimeout, "model-server-ready-timeout", time.Minute, "How long the model server has to get ready before it is restarted.")
+	flag.DurationVar(&modelServerOpts.HealthInterval, "model-server-health-interval", 10*time.Second, "The period of the health checks of the model server.")
+	flag.DurationVar(&modelServerOpts.MaxBackoff, "model-server-max-backoff", time.Minute, "The max wait before restarting a crashed model server.")
 	flag.Parse()
 	setupLog := log.Logger.WithName("setup")
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
@@ -82,10 +120,44 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err = (&controllers.AOMReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-	}).SetupWithManager(mgr); err != nil {
+	reconciler := &controllers.AOMReconciler{
+		Client:              mgr.GetClient(),
+		Scheme:              mgr.GetScheme(),
+		Workers:             collector.NewRegistry(),
+		EnableExecCollector: enableExecCollector,
+	}
+	if historyDir != "" {
+		history, err := checkpoint.NewStore(historyDir, historyOpts)
+		if err != nil {
+			setupLog.Error(err, "unable to open metric history", "dir", historyDir)
+			os.Exit(1)
+		}
+		reconciler.History = history
+	}
+	if remoteWriteAddr != "" {
+		reconciler.RemoteWrite = remote_write_collector.NewServer(remoteWriteAddr)
+		if err := mgr.Add(reconciler.RemoteWrite); err != nil {
+			setupLog.Error(err, "unable to set up remote-write receiver")
+			os.Exit(1)
+		}
+	}
+	if modelServerScript != "" {
+		modelServerOpts.Command = []string{modelServerPython, modelServerScript}
+		modelServerOpts.Dir = modelServerDir
+		if modelServerOpts.Dir == "" {
+			modelServerOpts.Dir = filepath.Dir(modelServerScript)
+			modelServerOpts.Command[1] = filepath.Base(modelServerScript)
+		}
+		modelServer, err := supervisor.New(modelServerOpts)
+		if err == nil {
+			err = mgr.Add(modelServer)
+		}
+		if err != nil {
+			setupLog.Error(err, "unable to set up model server")
+			os.Exit(1)
+		}
+	}
+	if err = reconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "AOM")
 		os.Exit(1)
 	}
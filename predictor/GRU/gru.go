@@ -8,97 +8,102 @@ import (
 	"github.com/LL-res/AOM/common/basetype"
 	"github.com/LL-res/AOM/common/consts"
 	"github.com/LL-res/AOM/common/errs"
+	"github.com/LL-res/AOM/predictor/jobs"
 	ptype "github.com/LL-res/AOM/predictor/type"
 	"github.com/LL-res/AOM/utils"
 	"go.uber.org/atomic"
-	"io"
 	"log"
 	"net"
-	"os"
-	"os/exec"
-	"runtime"
 	"strconv"
-	"time"
 )
 
 const (
-	pySocket       = "/tmp/gru.socket"
+	PySocket       = "/tmp/gru.socket"
 	RespRecvAdress = "/tmp/rra.socket"
 	Epochs         = 100
 	Nlayers        = 2
-	pythonMain     = "../../algorithms/DL/main.py"
-	PYTHON         = "../../algorithms/DL/aomenv/bin/python3"
-	pythonDir      = "../../algorithms/DL"
 )
 
+// Schema of the attributes in spec
+var Schema = RecurrentSchema("gru")
+
+// RecurrentSchema lists the attributes of the recurrent networks served by the model server, layer names their layers
+func RecurrentSchema(layer string) ptype.Schema {
+	return ptype.Schema{
+		{Name: "address", Description: "the unix socket the model server listens on", Type: ptype.String, Default: PySocket},
+		{Name: "resp_recv_address", Description: "the unix socket the responses of the model server are received on", Type: ptype.String, Default: RespRecvAdress},
+		{Name: "look_back", Description: "how many points the prediction is based on", Type: ptype.Int, Default: "100", Min: ptype.Bound(1)},
+		{Name: "look_forward", Description: "how many points to predict", Type: ptype.Int, Default: "60", Min: ptype.Bound(1)},
+		{Name: "batch_size", Description: "the batch size of training", Type: ptype.Int, Default: "6", Min: ptype.Bound(1)},
+		{Name: "train_size", Description: "how many points to train on, more than look_back", Type: ptype.Int, Required: true, Min: ptype.Bound(2)},
+		{Name: "epochs", Description: "the epochs of training", Type: ptype.Int, Default: strconv.Itoa(Epochs), Min: ptype.Bound(1)},
+		{Name: "n_layers", Description: fmt.Sprintf("the number of %s layers", layer), Type: ptype.Int, Default: strconv.Itoa(Nlayers), Min: ptype.Bound(1)},
+		ptype.Confidence,
+		{Name: "samples", Description: "how many forward passes with dropout the prediction intervals are sampled from, 1 turns them off, more needs n_layers of 2 or more since the dropout is between layers", Type: ptype.Int, Default: "1", Min: ptype.Bound(1)},
+		{Name: "debug", Description: "log the predictions", Type: ptype.Bool, Default: "false"},
+	}
+}
+
+func init() {
+	ptype.Register(consts.GRU, ptype.Model{
+		Schema: Schema,
+		Factory: func(worker collector.MetricCollector, values ptype.Values, withModelKey string) (ptype.Predictor, error) {
+			return newGRU(worker, values, withModelKey)
+		},
+	})
+}
+
 // 在controller里控制要不要进行predict或者train，status里记录了模型的状态
 // 在这里的预测服务只要专心进行预测即可
 
 func New(collectorWorker collector.MetricCollector, model map[string]string, withModelKey string) (*GRU, error) {
-	cmd := exec.Command(PYTHON, pythonMain)
-	go func() {
-		log.Println("starting main.py")
-		if err := cmd.Run(); err != nil {
-			log.Println("python cmd failed", err)
-		}
-	}()
-	time.Sleep(time.Second)
-	j, err := json.Marshal(model)
-	if err != nil {
-		return nil, err
-	}
-	param := Param{}
-	err = json.Unmarshal(j, &param)
-	if err != nil {
-		return nil, err
-	}
-	lookBack, err := strconv.Atoi(param.LookBack)
-	if err != nil {
-		return nil, err
-	}
-	lookForward, err := strconv.Atoi(param.LookForward)
-	if err != nil {
-		return nil, err
-	}
-	batchSize, err := strconv.Atoi(param.BatchSize)
-	if err != nil {
-		return nil, err
-	}
-	trainSize, err := strconv.Atoi(param.TrainSize)
-	if err != nil {
-		return nil, err
-	}
-	epochs, err := strconv.Atoi(param.Epochs)
+	values, err := Schema.Parse(model)
 	if err != nil {
 		return nil, err
 	}
-	nLayers, err := strconv.Atoi(param.NLayers)
-	if err != nil {
-		return nil, err
+	return newGRU(collectorWorker, values, withModelKey)
+}
+
+func newGRU(collectorWorker collector.MetricCollector, values ptype.Values, withModelKey string) (*GRU, error) {
+	return NewRecurrent(consts.GRU, collectorWorker, values, withModelKey)
+}
+
+// NewRecurrent builds a predictor of the recurrent network of kind, GRU or LSTM, served by the model server,
+// values are parsed by RecurrentSchema
+func NewRecurrent(kind string, collectorWorker collector.MetricCollector, values ptype.Values, withModelKey string) (*GRU, error) {
+	lookBack, trainSize := values.Int("look_back"), values.Int("train_size")
+	if trainSize <= lookBack {
+		return nil, fmt.Errorf("attr[train_size] %d must be more than attr[look_back] %d", trainSize, lookBack)
 	}
-	debug := false
-	if param.Debug == "true" {
-		debug = true
+	// a single layer has no dropout to sample, the intervals would have zero width
+	if nLayers, samples := values.Int("n_layers"), values.Int("samples"); nLayers < 2 && samples > 1 {
+		return nil, fmt.Errorf("attr[samples] %d needs attr[n_layers] of 2 or more, got %d", samples, nLayers)
 	}
+	// the model server is shared by the predictors, the operator supervises it, see --model-server-script
+	address := values.String("address")
 	return &GRU{
+		kind: kind,
 		Base: ptype.Base{
 			MetricHistory: collectorWorker.Send(),
 		},
 		withModelKey: withModelKey,
 		model: basetype.GRU{
-			Address:        param.Address,
-			RespRecvAdress: param.RespRecvAdress,
+			Address:        address,
+			RespRecvAdress: values.String("resp_recv_address"),
 			LookBack:       lookBack,
-			LookForward:    lookForward,
-			BatchSize:      batchSize,
+			LookForward:    values.Int("look_forward"),
+			BatchSize:      values.Int("batch_size"),
 			TrainSize:      trainSize,
-			Epochs:         epochs,
-			NLayers:        nLayers,
+			Epochs:         values.Int("epochs"),
+			NLayers:        values.Int("n_layers"),
 		},
 		collectorWorker: collectorWorker,
 		readyToPredict:  atomic.NewBool(false),
-		address:         param.Address,
-		debug:           debug,
+		lastJob:         atomic.NewString(""),
+		address:         address,
+		confidence:      values.Float("confidence"),
+		samples:         values.Int("samples"),
+		debug:           values.Bool("debug"),
 	}, nil
 
 }
@@ -108,7 +113,7 @@ func (g *GRU) Predict(ctx context.Context) (result ptype.PredictResult, err erro
 		return ptype.PredictResult{}, errs.UNREADY_TO_PREDICT
 	}
 	// 如果worker中的数据量不足，直接返回
-	if g.collectorWorker.DataCap() < g.model.LookForward {
+	if g.collectorWorker.DataCap() < g.model.LookBack {
 		return ptype.PredictResult{}, errs.NO_SUFFICENT_DATA
 	}
 	tempData := g.collectorWorker.Send()
@@ -121,9 +126,13 @@ func (g *GRU) Predict(ctx context.Context) (result ptype.PredictResult, err erro
 	}
 	req := Request{
 		Key:            g.withModelKey,
+		ModelType:      g.kind,
 		PredictHistory: predictHistory,
 		LookBack:       g.model.LookBack,
 		LookForward:    g.model.LookForward,
+		NLayers:        g.model.NLayers,
+		Confidence:     g.confidence,
+		Samples:        g.samples,
 	}
 	body, err := json.Marshal(req)
 	if err != nil {
@@ -146,6 +155,10 @@ func (g *GRU) Predict(ctx context.Context) (result ptype.PredictResult, err erro
 	result.StartMetric = predictHistory[len(predictHistory)-1]
 	result.PredictMetric = response.Prediction
 	result.Loss = response.Loss
+	if len(response.Lower) == len(response.Prediction) && len(response.Upper) == len(response.Prediction) {
+		result.Lower, result.Upper = response.Lower, response.Upper
+		result.Confidence = g.confidence
+	}
 	if err != nil {
 		return ptype.PredictResult{}, err
 	}
@@ -159,7 +172,7 @@ func (g *GRU) Predict(ctx context.Context) (result ptype.PredictResult, err erro
 }
 
 func (g *GRU) GetType() string {
-	return consts.GRU
+	return g.kind
 }
 
 func (g *GRU) Train(ctx context.Context) error {
@@ -174,16 +187,42 @@ func (g *GRU) Train(ctx context.Context) error {
 	for _, v := range TrainData {
 		TrainHistory = append(TrainHistory, v.Value)
 	}
+	// the results of all trainings come back on one listener, routed to the predictor by the id of the job
+	listener, err := jobs.Shared(g.model.RespRecvAdress)
+	if err != nil {
+		return err
+	}
+	job, err := listener.Submit(g.withModelKey, func(job jobs.Job) {
+		log.Printf("%s train job %s of %s %s, loss %v %s", g.kind, job.ID, job.Key, job.State, job.Loss, job.Error)
+		// a failed training leaves the model trained before in place
+		if job.State == jobs.Done {
+			g.readyToPredict.Store(true)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	g.lastJob.Store(job.ID)
 	req := Request{
 		Key:            g.withModelKey,
+		JobID:          job.ID,
+		ModelType:      g.kind,
 		RespRecvAdress: g.model.RespRecvAdress,
 		TrainHistory:   TrainHistory,
 		LookBack:       g.model.LookBack,
 		LookForward:    g.model.LookForward,
-		BatchSize:      g.model.TrainSize / 10,
+		BatchSize:      g.model.BatchSize,
 		Epochs:         g.model.Epochs,
 		NLayers:        g.model.NLayers,
 	}
+	if err := g.sendTrain(req); err != nil {
+		listener.Fail(job.ID, err)
+		return err
+	}
+	return nil
+}
+
+func (g *GRU) sendTrain(req Request) error {
 	reqJson, err := json.Marshal(req)
 	if err != nil {
 		return err
@@ -200,85 +239,51 @@ func (g *GRU) Train(ctx context.Context) error {
 	}()
 	// 客户端发送一次的数据接收到响应后断开连接
 	_, err = conn.Write(reqJson)
+	return err
+}
 
+// TrainStatus polls the latest training job of the predictor
+func (g *GRU) TrainStatus() (jobs.Job, bool) {
+	id := g.lastJob.Load()
+	if id == "" {
+		return jobs.Job{}, false
+	}
+	listener, err := jobs.Shared(g.model.RespRecvAdress)
 	if err != nil {
-		return err
+		return jobs.Job{}, false
 	}
-	// 由于训练时间较长
-	// 起一个协程去等输出，此刻直接返回
-	// 协程收到返回后进行状态更新
-	go func() {
-		if err := g.WaitAndUpdate(ctx); err != nil {
-			runtime.Goexit()
-		}
-	}()
-
-	return nil
-
+	return listener.Status(id)
 }
 
-func (g *GRU) WaitAndUpdate(ctx context.Context) error {
-	//如果socket文件存在，则进行移除
-	if _, err := os.Stat(RespRecvAdress); err == nil {
-		err := os.Remove(RespRecvAdress)
-		if err != nil {
-			log.Println(err)
-			return err
-		}
+// Report shows the latest training job in status
+func (g *GRU) Report() map[string]string {
+	job, ok := g.TrainStatus()
+	if !ok {
+		return nil
 	}
-	// 收到一次响应后直接断开
-	l, err := net.Listen("unix", RespRecvAdress)
-	if err != nil {
-		log.Println(err)
-		return err
+	res := map[string]string{
+		"job":   job.ID,
+		"state": string(job.State),
 	}
-	defer func() {
-		err := l.Close()
-		if err != nil {
-			log.Println(err)
-			return
-		}
-	}()
-	conn, err := l.Accept()
-	if err != nil {
-		log.Println(err)
-		return err
+	if job.State == jobs.Done {
+		res["loss"] = strconv.FormatFloat(job.Loss, 'g', 6, 64)
 	}
-	bufSize := 1024
-	buf := make([]byte, bufSize)
-	var res string
-	for {
-		n, err := conn.Read(buf)
-		if err == io.EOF {
-			res += string(buf[:n])
-			break
-		}
-		if err != nil {
-			log.Println(err)
-			return err
-		}
-		if n == bufSize {
-			res += string(buf)
-			continue
-		}
-		if n < bufSize {
-			res += string(buf[:n])
-			break
-		}
+	if job.Error != "" {
+		res["error"] = job.Error
 	}
-	resp := Response{}
-	err = json.Unmarshal([]byte(res), &resp)
-	if err != nil {
-		log.Println(err)
-		return err
+	return res
+}
+
+// Remove forgets the training jobs of the predictor
+func (g *GRU) Remove() {
+	if g.lastJob.Load() == "" {
+		return
 	}
-	if resp.Error != "" {
-		log.Println(resp.Error)
-		return fmt.Errorf("python error : %s", resp.Error)
+	listener, err := jobs.Shared(g.model.RespRecvAdress)
+	if err != nil {
+		return
 	}
-	log.Printf("train response : %v", resp)
-	g.readyToPredict.Store(resp.Trained)
-	return nil
+	listener.Forget(g.withModelKey)
 }
 
 func (g *GRU) Key() string {
@@ -13,6 +13,12 @@ import (
 	"time"
 )
 
+// the model server the tests start
+const (
+	PYTHON    = "../../algorithms/DL/aomenv/bin/python3"
+	pythonDir = "../../algorithms/DL"
+)
+
 var (
 	ctx context.Context
 	gru *GRU
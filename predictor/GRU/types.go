@@ -10,17 +10,25 @@ import (
 
 type GRU struct {
 	ptype.Base
+	// kind is the network served, GRU or LSTM
+	kind            string
 	withModelKey    string
 	model           basetype.GRU
 	collectorWorker collector.MetricCollector
 	readyToPredict  *atomic.Bool
-	address         string
-	ScaleTargetRef  autoscalingv2.CrossVersionObjectReference
-	debug           bool
+	// the id of the latest training job
+	lastJob        *atomic.String
+	address        string
+	confidence     float64
+	samples        int
+	ScaleTargetRef autoscalingv2.CrossVersionObjectReference
+	debug          bool
 }
 
 type Request struct {
 	Key            string    `json:"key"`
+	JobID          string    `json:"job_id,omitempty"`
+	ModelType      string    `json:"model_type"`
 	PredictHistory []float64 `json:"predict_history,omitempty"`
 	TrainHistory   []float64 `json:"train_history,omitempty"`
 	RespRecvAdress string    `json:"resp_recv_address"`
@@ -29,25 +37,19 @@ type Request struct {
 	BatchSize      int       `json:"batch_size,omitempty"`
 	Epochs         int       `json:"epochs,omitempty"`
 	NLayers        int       `json:"n_layers,omitempty"`
+	// the prediction intervals are sampled from Samples forward passes with dropout on, at the Confidence level
+	Confidence float64 `json:"confidence,omitempty"`
+	Samples    int     `json:"samples,omitempty"`
 }
 
 type Response struct {
+	// JobID is echoed from the request
+	JobID string `json:"job_id,omitempty"`
 	//模型训练的误差参数，例如均方误差值
 	Loss       float64   `json:"loss"`
 	Trained    bool      `json:"trained"`
 	Prediction []float64 `json:"prediction"`
+	Lower      []float64 `json:"lower,omitempty"`
+	Upper      []float64 `json:"upper,omitempty"`
 	Error      string    `json:"error"`
 }
-
-// spec fields
-type Param struct {
-	Address        string `json:"address"`
-	RespRecvAdress string `json:"resp_recv_address"`
-	LookBack       string `json:"look_back"`
-	LookForward    string `json:"look_forward"`
-	BatchSize      string `json:"batch_size"`
-	TrainSize      string `json:"train_size"`
-	Epochs         string `json:"epochs"`
-	NLayers        string `json:"n_layers"`
-	Debug          string `json:"debug"`
-}
@@ -0,0 +1,28 @@
+package LSTM
+
+import (
+	"github.com/LL-res/AOM/collector"
+	"github.com/LL-res/AOM/common/consts"
+	"github.com/LL-res/AOM/predictor/GRU"
+	ptype "github.com/LL-res/AOM/predictor/type"
+)
+
+// Schema of the attributes in spec, the LSTM is served by the same model server as the GRU
+var Schema = GRU.RecurrentSchema("lstm")
+
+func init() {
+	ptype.Register(consts.LSTM, ptype.Model{
+		Schema: Schema,
+		Factory: func(worker collector.MetricCollector, values ptype.Values, withModelKey string) (ptype.Predictor, error) {
+			return GRU.NewRecurrent(consts.LSTM, worker, values, withModelKey)
+		},
+	})
+}
+
+func New(collectorWorker collector.MetricCollector, model map[string]string, withModelKey string) (*GRU.GRU, error) {
+	values, err := Schema.Parse(model)
+	if err != nil {
+		return nil, err
+	}
+	return GRU.NewRecurrent(consts.LSTM, collectorWorker, values, withModelKey)
+}
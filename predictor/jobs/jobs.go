@@ -0,0 +1,226 @@
+package jobs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/LL-res/AOM/log"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+type State string
+
+// DefaultTimeout is how long a job waits for its result before it fails, e.g. when the model server restarted
+const DefaultTimeout = time.Hour
+
+const (
+	Pending State = "pending"
+	Done    State = "done"
+	Failed  State = "failed"
+)
+
+// Job is an asynchronous training of the model of Key
+type Job struct {
+	ID        string
+	Key       string
+	State     State
+	Loss      float64
+	Error     string
+	Submitted time.Time
+	Finished  time.Time
+}
+
+// Result is what the model server sends back when a training is over, it echoes the id of the job
+type Result struct {
+	JobID   string  `json:"job_id"`
+	Trained bool    `json:"trained"`
+	Loss    float64 `json:"loss"`
+	Error   string  `json:"error"`
+}
+
+type job struct {
+	Job
+	done func(Job)
+	// fails the job once the timeout passed
+	timer *time.Timer
+}
+
+// Listener receives the results of the trainings on a single long-lived unix socket and routes them to their jobs by id
+type Listener struct {
+	address string
+	l       net.Listener
+	timeout time.Duration
+
+	mu   sync.Mutex
+	jobs map[string]*job
+	// the latest job of each key, the finished ones before it are dropped
+	latest map[string]string
+}
+
+var (
+	shared   = make(map[string]*Listener)
+	sharedMu sync.Mutex
+)
+
+// Shared returns the listener on address, started by the first caller and kept for the life of the process
+func Shared(address string) (*Listener, error) {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	if l, ok := shared[address]; ok {
+		return l, nil
+	}
+	l, err := Listen(address)
+	if err != nil {
+		return nil, err
+	}
+	shared[address] = l
+	return l, nil
+}
+
+// Listen removes a stale socket at address and starts listening on it
+func Listen(address string) (*Listener, error) {
+	if _, err := os.Stat(address); err == nil {
+		if err := os.Remove(address); err != nil {
+			return nil, err
+		}
+	}
+	l, err := net.Listen("unix", address)
+	if err != nil {
+		return nil, err
+	}
+	res := &Listener{
+		address: address,
+		l:       l,
+		timeout: DefaultTimeout,
+		jobs:    make(map[string]*job),
+		latest:  make(map[string]string),
+	}
+	go res.serve()
+	return res, nil
+}
+
+func (l *Listener) Address() string {
+	return l.address
+}
+
+// Submit registers a pending job for the model of key, done is called with the job once its result arrives
+// or it failed, at the latest after the timeout
+func (l *Listener) Submit(key string, done func(Job)) (Job, error) {
+	id, err := newID()
+	if err != nil {
+		return Job{}, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if prev, ok := l.jobs[l.latest[key]]; ok && prev.State != Pending {
+		delete(l.jobs, prev.ID)
+	}
+	j := &job{Job: Job{ID: id, Key: key, State: Pending, Submitted: time.Now()}, done: done}
+	j.timer = time.AfterFunc(l.timeout, func() {
+		l.finish(Result{JobID: id, Error: fmt.Sprintf("no result within %s", l.timeout)})
+	})
+	l.jobs[id] = j
+	l.latest[key] = id
+	return j.Job, nil
+}
+
+// Forget drops the jobs of key without calling their done, e.g. once its predictor is removed
+func (l *Listener) Forget(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for id, j := range l.jobs {
+		if j.Key == key {
+			j.timer.Stop()
+			delete(l.jobs, id)
+		}
+	}
+	delete(l.latest, key)
+}
+
+// Fail finishes a pending job that will get no result, e.g. when its request could not be sent
+func (l *Listener) Fail(id string, err error) {
+	l.finish(Result{JobID: id, Error: err.Error()})
+}
+
+// Status polls a job
+func (l *Listener) Status(id string) (Job, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	j, ok := l.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return j.Job, true
+}
+
+func (l *Listener) Close() error {
+	return l.l.Close()
+}
+
+func (l *Listener) serve() {
+	for {
+		conn, err := l.l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			log.Logger.Error(err, "accept training result failed", "address", l.address)
+			continue
+		}
+		go l.receive(conn)
+	}
+}
+
+// receive reads a result, the model server closes the connection after writing it
+func (l *Listener) receive(conn net.Conn) {
+	defer conn.Close()
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		log.Logger.Error(err, "read training result failed", "address", l.address)
+		return
+	}
+	res := Result{}
+	if err := json.Unmarshal(data, &res); err != nil {
+		log.Logger.Error(err, "decode training result failed", "address", l.address)
+		return
+	}
+	l.finish(res)
+}
+
+func (l *Listener) finish(res Result) {
+	l.mu.Lock()
+	j, ok := l.jobs[res.JobID]
+	if !ok || j.State != Pending {
+		l.mu.Unlock()
+		log.Logger.Info("drop training result of an unknown job", "job", res.JobID)
+		return
+	}
+	j.timer.Stop()
+	j.State, j.Loss, j.Error, j.Finished = Done, res.Loss, res.Error, time.Now()
+	if res.Error != "" || !res.Trained {
+		j.State = Failed
+	}
+	// a job superseded while pending is not polled anymore
+	if l.latest[j.Key] != j.ID {
+		delete(l.jobs, j.ID)
+	}
+	finished := j.Job
+	l.mu.Unlock()
+	if j.done != nil {
+		j.done(finished)
+	}
+}
+
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,129 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/LL-res/AOM/log"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// reply plays the model server sending the result of a training
+func reply(t *testing.T, address string, res Result) {
+	conn, err := net.Dial("unix", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := json.NewEncoder(conn).Encode(res); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListener(t *testing.T) {
+	log.Init()
+	l, err := Listen(filepath.Join(t.TempDir(), "rra.socket"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	finished := make(chan Job, 2)
+	a, err := l.Submit("a", func(j Job) { finished <- j })
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := l.Submit("b", func(j Job) { finished <- j })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.ID == b.ID || a.State != Pending {
+		t.Fatalf("got %+v and %+v", a, b)
+	}
+
+	// the results come back in any order, each reaches its own job
+	reply(t, l.Address(), Result{JobID: "unknown", Trained: true})
+	reply(t, l.Address(), Result{JobID: b.ID, Error: "out of memory"})
+	reply(t, l.Address(), Result{JobID: a.ID, Trained: true, Loss: 0.1})
+	got := make(map[string]Job)
+	for i := 0; i < 2; i++ {
+		select {
+		case j := <-finished:
+			got[j.Key] = j
+		case <-time.After(5 * time.Second):
+			t.Fatal("results not routed")
+		}
+	}
+	if j := got["a"]; j.ID != a.ID || j.State != Done || j.Loss != 0.1 {
+		t.Errorf("got %+v", j)
+	}
+	if j := got["b"]; j.ID != b.ID || j.State != Failed || j.Error != "out of memory" {
+		t.Errorf("got %+v", j)
+	}
+	if j, ok := l.Status(a.ID); !ok || j.State != Done {
+		t.Errorf("got %+v, %v", j, ok)
+	}
+
+	// a new job of the key drops the finished one
+	c, err := l.Submit("a", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := l.Status(a.ID); ok {
+		t.Error("the finished job of a should be dropped")
+	}
+	l.Fail(c.ID, errors.New("dial failed"))
+	if j, _ := l.Status(c.ID); j.State != Failed || j.Error != "dial failed" {
+		t.Errorf("got %+v", j)
+	}
+}
+
+func TestListenerExpire(t *testing.T) {
+	log.Init()
+	l, err := Listen(filepath.Join(t.TempDir(), "rra.socket"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	l.timeout = 50 * time.Millisecond
+
+	finished := make(chan Job, 1)
+	a, err := l.Submit("a", func(j Job) { finished <- j })
+	if err != nil {
+		t.Fatal(err)
+	}
+	// superseded while pending, dropped once it expires
+	b, err := l.Submit("a", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case j := <-finished:
+		if j.ID != a.ID || j.State != Failed || j.Error == "" {
+			t.Errorf("got %+v", j)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("pending job not expired")
+	}
+	if _, ok := l.Status(a.ID); ok {
+		t.Error("the superseded job of a should be dropped")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if j, ok := l.Status(b.ID); !ok || j.State != Failed {
+		t.Errorf("got %+v, %v", j, ok)
+	}
+
+	// a forgotten job gets no result
+	c, err := l.Submit("c", func(j Job) { t.Errorf("forgotten job finished: %+v", j) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Forget("c")
+	if _, ok := l.Status(c.ID); ok {
+		t.Error("the jobs of c should be forgotten")
+	}
+	reply(t, l.Address(), Result{JobID: c.ID, Trained: true})
+	time.Sleep(100 * time.Millisecond)
+}
@@ -0,0 +1,130 @@
+package modelserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const defaultTimeout = 10 * time.Second
+
+// Client calls a model server, over tcp or a unix socket
+type Client struct {
+	base   string
+	client *http.Client
+}
+
+// NewClient accepts http(s) urls, host:port, and unix sockets as unix:///path or a bare absolute path
+func NewClient(address string, timeout time.Duration) (*Client, error) {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	res := &Client{client: &http.Client{Timeout: timeout}}
+	socket := ""
+	switch {
+	case strings.HasPrefix(address, "unix://"):
+		socket = strings.TrimPrefix(address, "unix://")
+	case strings.HasPrefix(address, "/"):
+		socket = address
+	case strings.HasPrefix(address, "http://"), strings.HasPrefix(address, "https://"):
+		res.base = strings.TrimSuffix(address, "/")
+	default:
+		res.base = "http://" + address
+	}
+	if socket != "" {
+		dialer := &net.Dialer{}
+		res.client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		}
+		// the host is ignored by the dialer
+		res.base = "http://unix"
+	}
+	if _, err := url.Parse(res.base); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Train starts training the model of key, the server trains in the background
+func (c *Client) Train(ctx context.Context, key string, req TrainRequest) (Status, error) {
+	status := Status{}
+	err := c.do(ctx, http.MethodPost, modelPath(key, trainPath), req, &status)
+	return status, err
+}
+
+func (c *Client) Predict(ctx context.Context, key string, req PredictRequest) (PredictResponse, error) {
+	resp := PredictResponse{}
+	err := c.do(ctx, http.MethodPost, modelPath(key, predictPath), req, &resp)
+	return resp, err
+}
+
+func (c *Client) Status(ctx context.Context, key string) (Status, error) {
+	status := Status{}
+	err := c.do(ctx, http.MethodGet, modelPath(key), nil, &status)
+	return status, err
+}
+
+func (c *Client) Models(ctx context.Context) ([]Status, error) {
+	res := make([]Status, 0)
+	err := c.do(ctx, http.MethodGet, modelsPath, nil, &res)
+	return res, err
+}
+
+func (c *Client) Delete(ctx context.Context, key string) error {
+	return c.do(ctx, http.MethodDelete, modelPath(key), nil, nil)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		errResp := ErrorResponse{}
+		_ = json.Unmarshal(data, &errResp)
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			return fmt.Errorf("%w, %s %s", ErrNotFound, method, path)
+		case http.StatusConflict:
+			return fmt.Errorf("%w, %s %s", ErrNotTrained, method, path)
+		}
+		return fmt.Errorf("model server %s %s: %s: %s", method, path, resp.Status, errResp.Error)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+func modelPath(key string, sub ...string) string {
+	return strings.Join(append([]string{modelsPath, url.PathEscape(key)}, sub...), "/")
+}
@@ -0,0 +1,172 @@
+package modelserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/LL-res/AOM/common/errs"
+	"github.com/LL-res/AOM/fake"
+	"github.com/LL-res/AOM/log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// standIn forecasts the last point of the history, its models are trained at once
+type standIn struct {
+	mu     sync.Mutex
+	models map[string]Status
+}
+
+func (s *standIn) Train(ctx context.Context, key string, req TrainRequest) (Status, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := Status{Key: key, ModelType: req.ModelType, State: StateReady, Loss: 0.5, UpdatedAt: time.Now()}
+	s.models[key] = status
+	return status, nil
+}
+
+func (s *standIn) Predict(ctx context.Context, key string, req PredictRequest) (PredictResponse, error) {
+	status, err := s.Status(ctx, key)
+	if err != nil {
+		return PredictResponse{}, err
+	}
+	if status.State != StateReady {
+		return PredictResponse{}, ErrNotTrained
+	}
+	last := req.History[len(req.History)-1]
+	resp := PredictResponse{Loss: status.Loss}
+	for i := 0; i < req.LookForward; i++ {
+		resp.Prediction = append(resp.Prediction, last)
+		resp.Lower = append(resp.Lower, last-1)
+		resp.Upper = append(resp.Upper, last+1)
+	}
+	return resp, nil
+}
+
+func (s *standIn) Status(ctx context.Context, key string) (Status, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status, ok := s.models[key]
+	if !ok {
+		return Status{}, ErrNotFound
+	}
+	return status, nil
+}
+
+func (s *standIn) Models(ctx context.Context) ([]Status, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]Status, 0, len(s.models))
+	for _, status := range s.models {
+		res = append(res, status)
+	}
+	return res, nil
+}
+
+func (s *standIn) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.models[key]; !ok {
+		return ErrNotFound
+	}
+	delete(s.models, key)
+	return nil
+}
+
+// serve starts the stand-in on tcp or a unix socket, returning its address
+func serve(t *testing.T, network string) string {
+	handler := Handler(&standIn{models: make(map[string]Status)})
+	if network == "tcp" {
+		server := httptest.NewServer(handler)
+		t.Cleanup(server.Close)
+		return server.URL
+	}
+	socket := filepath.Join(t.TempDir(), "model.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: handler}
+	go server.Serve(l)
+	t.Cleanup(func() { server.Close() })
+	return "unix://" + socket
+}
+
+func TestRemote(t *testing.T) {
+	log.Init()
+	for _, network := range []string{"tcp", "unix"} {
+		t.Run(network, func(t *testing.T) {
+			worker := &fake.CollectorWorker{
+				N:        300,
+				Function: func(i int) float64 { return float64(i) },
+				Start:    time.Now().Add(-time.Hour),
+				Interval: time.Second,
+			}
+			// the key holds characters to escape in the path
+			p, err := New(worker, map[string]string{"address": serve(t, network), "train_size": "200", "look_forward": "3"}, "name$unit$sum(rate(x[1m]))/2$model_server")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := p.Predict(context.Background()); err != errs.UNREADY_TO_PREDICT {
+				t.Fatalf("got %v before training, want %v", err, errs.UNREADY_TO_PREDICT)
+			}
+			if err := p.Train(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			status, err := p.Status(context.Background())
+			if err != nil || status.State != StateReady || status.ModelType != "GRU" || status.Key != p.Key() {
+				t.Fatalf("got %+v, %v", status, err)
+			}
+			res, err := p.Predict(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(res.PredictMetric) != "[299 299 299]" || res.StartMetric != 299 || res.Loss != 0.5 ||
+				fmt.Sprint(res.Upper) != "[300 300 300]" || res.Confidence != 0.95 {
+				t.Errorf("got %+v", res)
+			}
+			if report := p.Report(); report["state"] != StateReady || report["loss"] != "0.5" {
+				t.Errorf("got report %v", report)
+			}
+			models, err := p.client.Models(context.Background())
+			if err != nil || len(models) != 1 {
+				t.Fatalf("got %v, %v", models, err)
+			}
+			p.Remove()
+			if report := p.Report(); report != nil {
+				t.Errorf("got report %v of a removed model", report)
+			}
+			if err := p.client.Delete(context.Background(), p.Key()); !errors.Is(err, ErrNotFound) {
+				t.Errorf("got %v, want %v", err, ErrNotFound)
+			}
+		})
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+	}{
+		{address: "http://model:8080/", want: "http://model:8080"},
+		{address: "model:8080", want: "http://model:8080"},
+		{address: "unix:///tmp/model.sock", want: "http://unix"},
+		{address: "/tmp/model.sock", want: "http://unix"},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			c, err := NewClient(tt.address, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.base != tt.want {
+				t.Errorf("got %s, want %s", c.base, tt.want)
+			}
+		})
+	}
+}
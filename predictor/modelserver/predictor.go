@@ -0,0 +1,202 @@
+package modelserver
+
+import (
+	"context"
+	"errors"
+	"github.com/LL-res/AOM/collector"
+	"github.com/LL-res/AOM/common/consts"
+	"github.com/LL-res/AOM/common/errs"
+	"github.com/LL-res/AOM/log"
+	ptype "github.com/LL-res/AOM/predictor/type"
+	"strconv"
+	"time"
+)
+
+// how long Report and Remove wait for the server, they run on the reconcile of the instance
+const reportTimeout = 2 * time.Second
+
+// Schema of the attributes in spec
+var Schema = ptype.Schema{
+	{Name: "address", Description: "the model server, an http url, host:port, or a unix socket as unix:///path", Type: ptype.String, Required: true},
+	{Name: "model_type", Description: "the kind of model the server builds", Type: ptype.String, Default: consts.GRU},
+	{Name: "look_back", Description: "how many points the prediction is based on", Type: ptype.Int, Default: "100", Min: ptype.Bound(1)},
+	{Name: "look_forward", Description: "how many points to predict", Type: ptype.Int, Default: "60", Min: ptype.Bound(1)},
+	{Name: "train_size", Description: "how many points to train on, more than look_back", Type: ptype.Int, Required: true, Min: ptype.Bound(2)},
+	{Name: "batch_size", Description: "the batch size of training", Type: ptype.Int, Default: "6", Min: ptype.Bound(1)},
+	{Name: "epochs", Description: "the epochs of training", Type: ptype.Int, Default: "100", Min: ptype.Bound(1)},
+	{Name: "n_layers", Description: "the number of layers of the network", Type: ptype.Int, Default: "2", Min: ptype.Bound(1)},
+	ptype.Confidence,
+	{Name: "samples", Description: "how many samples a sampling server bounds the predictions with, 1 turns the bounds off, the servers sampling dropout like algorithms/DL/server.py give bounds of zero width with n_layers 1", Type: ptype.Int, Default: "1", Min: ptype.Bound(1)},
+	{Name: "timeout", Description: "the timeout of a call to the server", Type: ptype.Duration, Default: "10s"},
+	{Name: "debug", Description: "log the predictions", Type: ptype.Bool, Default: "false"},
+}
+
+func init() {
+	ptype.Register(consts.MODEL_SERVER, ptype.Model{
+		Schema: Schema,
+		Factory: func(worker collector.MetricCollector, values ptype.Values, withModelKey string) (ptype.Predictor, error) {
+			return newRemote(worker, values, withModelKey)
+		},
+	})
+}
+
+// Remote predicts with a model living in a model server, the model is named by the key of the predictor
+type Remote struct {
+	client          *Client
+	modelType       string
+	lookBack        int
+	lookForward     int
+	trainSize       int
+	batchSize       int
+	epochs          int
+	nLayers         int
+	confidence      float64
+	samples         int
+	debug           bool
+	withModelKey    string
+	collectorWorker collector.MetricCollector
+}
+
+func New(collectorWorker collector.MetricCollector, model map[string]string, withModelKey string) (*Remote, error) {
+	values, err := Schema.Parse(model)
+	if err != nil {
+		return nil, err
+	}
+	return newRemote(collectorWorker, values, withModelKey)
+}
+
+func newRemote(collectorWorker collector.MetricCollector, values ptype.Values, withModelKey string) (*Remote, error) {
+	lookBack, trainSize := values.Int("look_back"), values.Int("train_size")
+	if trainSize <= lookBack {
+		return nil, errors.New("attr[train_size] must be more than attr[look_back]")
+	}
+	client, err := NewClient(values.String("address"), values.Duration("timeout"))
+	if err != nil {
+		return nil, err
+	}
+	return &Remote{
+		client:          client,
+		modelType:       values.String("model_type"),
+		lookBack:        lookBack,
+		lookForward:     values.Int("look_forward"),
+		trainSize:       trainSize,
+		batchSize:       values.Int("batch_size"),
+		epochs:          values.Int("epochs"),
+		nLayers:         values.Int("n_layers"),
+		confidence:      values.Float("confidence"),
+		samples:         values.Int("samples"),
+		debug:           values.Bool("debug"),
+		withModelKey:    withModelKey,
+		collectorWorker: collectorWorker,
+	}, nil
+}
+
+// Train hands the latest train_size points to the server, which trains in the background,
+// the model is unready to predict until it is done
+func (p *Remote) Train(ctx context.Context) error {
+	history, err := p.history(p.trainSize)
+	if err != nil {
+		return err
+	}
+	status, err := p.client.Train(ctx, p.withModelKey, TrainRequest{
+		ModelType:   p.modelType,
+		History:     history,
+		LookBack:    p.lookBack,
+		LookForward: p.lookForward,
+		BatchSize:   p.batchSize,
+		Epochs:      p.epochs,
+		NLayers:     p.nLayers,
+	})
+	if err != nil {
+		return err
+	}
+	log.Logger.Info("model server training", "key", p.withModelKey, "state", status.State)
+	return nil
+}
+
+func (p *Remote) Predict(ctx context.Context) (ptype.PredictResult, error) {
+	history, err := p.history(p.lookBack)
+	if err != nil {
+		return ptype.PredictResult{}, err
+	}
+	resp, err := p.client.Predict(ctx, p.withModelKey, PredictRequest{
+		History:     history,
+		LookForward: p.lookForward,
+		Confidence:  p.confidence,
+		Samples:     p.samples,
+	})
+	if errors.Is(err, ErrNotTrained) || errors.Is(err, ErrNotFound) {
+		return ptype.PredictResult{}, errs.UNREADY_TO_PREDICT
+	}
+	if err != nil {
+		return ptype.PredictResult{}, err
+	}
+	if p.debug {
+		log.Logger.Info("predict metrics", "key", p.withModelKey, "metrics", resp.Prediction)
+	}
+	result := ptype.PredictResult{
+		StartMetric:   history[len(history)-1],
+		Loss:          resp.Loss,
+		PredictMetric: resp.Prediction,
+	}
+	if len(resp.Lower) == len(resp.Prediction) && len(resp.Upper) == len(resp.Prediction) {
+		result.Lower, result.Upper = resp.Lower, resp.Upper
+		result.Confidence = p.confidence
+	}
+	return result, nil
+}
+
+// Status asks the server about the model
+func (p *Remote) Status(ctx context.Context) (Status, error) {
+	return p.client.Status(ctx, p.withModelKey)
+}
+
+// Report shows the state of the model on the server in status
+func (p *Remote) Report() map[string]string {
+	ctx, cancel := context.WithTimeout(context.Background(), reportTimeout)
+	defer cancel()
+	status, err := p.Status(ctx)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return map[string]string{"error": err.Error()}
+	}
+	res := map[string]string{"state": status.State}
+	if status.State == StateReady {
+		res["loss"] = strconv.FormatFloat(status.Loss, 'g', 6, 64)
+	}
+	if status.Error != "" {
+		res["error"] = status.Error
+	}
+	return res
+}
+
+// Remove drops the model from the server
+func (p *Remote) Remove() {
+	ctx, cancel := context.WithTimeout(context.Background(), reportTimeout)
+	defer cancel()
+	if err := p.client.Delete(ctx, p.withModelKey); err != nil && !errors.Is(err, ErrNotFound) {
+		log.Logger.Error(err, "delete model failed", "key", p.withModelKey)
+	}
+}
+
+func (p *Remote) history(n int) ([]float64, error) {
+	metrics, err := p.collectorWorker.Window(0, n)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]float64, 0, len(metrics))
+	for _, m := range metrics {
+		res = append(res, m.Value)
+	}
+	return res, nil
+}
+
+func (p *Remote) GetType() string {
+	return consts.MODEL_SERVER
+}
+
+func (p *Remote) Key() string {
+	return p.withModelKey
+}
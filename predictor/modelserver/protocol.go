@@ -0,0 +1,79 @@
+package modelserver
+
+import (
+	"errors"
+	"time"
+)
+
+// Version of the protocol, the prefix of every path
+const Version = "v1"
+
+// The routes of the protocol, a model is named by the key of the predictor using it:
+//
+//	GET    /v1/models                 lists the Status of every model
+//	GET    /v1/models/{key}           Status of a model
+//	DELETE /v1/models/{key}           drops a model
+//	POST   /v1/models/{key}/train     TrainRequest, answers 202 and a Status, training goes on in the background
+//	POST   /v1/models/{key}/predict   PredictRequest, answers a PredictResponse
+//
+// failures answer an ErrorResponse, 404 for an unknown model and 409 for a model not trained yet
+const (
+	modelsPath  = "/" + Version + "/models"
+	trainPath   = "train"
+	predictPath = "predict"
+)
+
+// the states of a model
+const (
+	StateTraining = "training"
+	StateReady    = "ready"
+	StateFailed   = "failed"
+)
+
+var (
+	// ErrNotFound is answered with 404, the server has no model of the key
+	ErrNotFound = errors.New("model not found")
+	// ErrNotTrained is answered with 409, the model has not finished training
+	ErrNotTrained = errors.New("model not trained")
+)
+
+type TrainRequest struct {
+	// ModelType is the kind of model the server builds, e.g. GRU or LSTM
+	ModelType   string    `json:"model_type"`
+	History     []float64 `json:"history"`
+	LookBack    int       `json:"look_back"`
+	LookForward int       `json:"look_forward"`
+	BatchSize   int       `json:"batch_size,omitempty"`
+	Epochs      int       `json:"epochs,omitempty"`
+	NLayers     int       `json:"n_layers,omitempty"`
+}
+
+type PredictRequest struct {
+	History     []float64 `json:"history"`
+	LookForward int       `json:"look_forward"`
+	// the prediction intervals are asked for at the Confidence level, Samples is a hint to sampling servers
+	Confidence float64 `json:"confidence,omitempty"`
+	Samples    int     `json:"samples,omitempty"`
+}
+
+type PredictResponse struct {
+	Prediction []float64 `json:"prediction"`
+	// Lower and Upper are left out by servers giving no intervals
+	Lower []float64 `json:"lower,omitempty"`
+	Upper []float64 `json:"upper,omitempty"`
+	// Loss of the last training, e.g. the mean squared error
+	Loss float64 `json:"loss"`
+}
+
+type Status struct {
+	Key       string    `json:"key"`
+	ModelType string    `json:"model_type"`
+	State     string    `json:"state"`
+	Loss      float64   `json:"loss"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
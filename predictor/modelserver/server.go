@@ -0,0 +1,101 @@
+package modelserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Backend is what a model server does with its models, Handler serves it over the protocol,
+// a Go stand-in for the forecasting services implements it
+type Backend interface {
+	// Train returns once training started, the Status of the model tells when it is over
+	Train(ctx context.Context, key string, req TrainRequest) (Status, error)
+	Predict(ctx context.Context, key string, req PredictRequest) (PredictResponse, error)
+	Status(ctx context.Context, key string) (Status, error)
+	Models(ctx context.Context) ([]Status, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Handler serves backend over the protocol, the errors ErrNotFound and ErrNotTrained of the backend answer 404 and 409
+func Handler(backend Backend) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.EscapedPath()
+		if path == modelsPath {
+			if r.Method != http.MethodGet {
+				writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+				return
+			}
+			models, err := backend.Models(r.Context())
+			respond(w, http.StatusOK, models, err)
+			return
+		}
+		if !strings.HasPrefix(path, modelsPath+"/") {
+			writeError(w, http.StatusNotFound, errors.New("unknown path, the protocol is "+Version))
+			return
+		}
+		parts := strings.Split(strings.TrimPrefix(path, modelsPath+"/"), "/")
+		key, err := url.PathUnescape(parts[0])
+		if err != nil || key == "" || len(parts) > 2 {
+			writeError(w, http.StatusBadRequest, errors.New("malformed model key"))
+			return
+		}
+		route := r.Method
+		if len(parts) == 2 {
+			route += " " + parts[1]
+		}
+		switch route {
+		case http.MethodGet:
+			status, err := backend.Status(r.Context(), key)
+			respond(w, http.StatusOK, status, err)
+		case http.MethodDelete:
+			respond(w, http.StatusNoContent, nil, backend.Delete(r.Context(), key))
+		case http.MethodPost + " " + trainPath:
+			req := TrainRequest{}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			status, err := backend.Train(r.Context(), key, req)
+			respond(w, http.StatusAccepted, status, err)
+		case http.MethodPost + " " + predictPath:
+			req := PredictRequest{}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			resp, err := backend.Predict(r.Context(), key, req)
+			respond(w, http.StatusOK, resp, err)
+		default:
+			writeError(w, http.StatusNotFound, errors.New("unknown route "+route))
+		}
+	})
+}
+
+func respond(w http.ResponseWriter, code int, body interface{}, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, ErrNotTrained):
+		writeError(w, http.StatusConflict, err)
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+	case body == nil:
+		w.WriteHeader(code)
+	default:
+		writeJSON(w, code, body)
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, ErrorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(body)
+}
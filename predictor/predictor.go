@@ -1,15 +1,20 @@
 package predictor
 
 import (
-	"context"
-	"errors"
-	"github.com/LL-res/AOM/algorithms/holt_winter"
 	"github.com/LL-res/AOM/collector"
-	"github.com/LL-res/AOM/common/consts"
-	"github.com/LL-res/AOM/predictor/GRU"
+	"github.com/LL-res/AOM/common/basetype"
 	ptype "github.com/LL-res/AOM/predictor/type"
 	"github.com/LL-res/AOM/utils"
 	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sort"
+
+	// the built-in models register themselves, other models are added by importing their package
+	_ "github.com/LL-res/AOM/algorithms/arima"
+	_ "github.com/LL-res/AOM/algorithms/holt_winter"
+	_ "github.com/LL-res/AOM/predictor/GRU"
+	_ "github.com/LL-res/AOM/predictor/LSTM"
+	_ "github.com/LL-res/AOM/predictor/modelserver"
 )
 
 type Param struct {
@@ -20,12 +25,7 @@ type Param struct {
 }
 
 // predictor is an interface providing methods for making a prediction based on a model, a time to predict and values
-type Predictor interface {
-	Predict(ctx context.Context) (ptype.PredictResult, error)
-	GetType() string
-	Train(ctx context.Context) error
-	Key() string
-}
+type Predictor = ptype.Predictor
 type Base struct {
 	MetricHistory []collector.Metric // 存储着全部
 	//socket client
@@ -66,21 +66,29 @@ type PredictResult struct {
 //	func (m *ModelPredict) Train(ctx context.Context) error {
 //		return nil
 //	}
+
+// NewPredictor builds the predictor of the model type registered under the type in WithModelKey
 func NewPredictor(param Param) (Predictor, error) {
-	switch utils.GetModelType(param.WithModelKey) {
-	case consts.GRU:
-		pred, err := GRU.New(param.MetricCollector, param.Model, param.WithModelKey)
-		if err != nil {
-			return nil, err
-		}
-		return pred, nil
-	case consts.HOLT_WINTER:
-		pred, err := holt_winter.New(param.MetricCollector, param.Model, param.WithModelKey)
-		if err != nil {
-			return nil, err
+	model, err := ptype.Lookup(utils.GetModelType(param.WithModelKey))
+	if err != nil {
+		return nil, err
+	}
+	return model.New(param.MetricCollector, param.Model, param.WithModelKey)
+}
+
+// ValidateModels checks the models of every metric against the schemas of their types,
+// the unknown attributes are returned as warnings
+func ValidateModels(models map[string][]basetype.Model, fldPath *field.Path) (errList, warnings field.ErrorList) {
+	keys := make([]string, 0, len(models))
+	for k := range models {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for i, model := range models[k] {
+			errs, warns := ptype.Validate(model.Type, model.Attr, fldPath.Key(k).Index(i))
+			errList, warnings = append(errList, errs...), append(warnings, warns...)
 		}
-		return pred, nil
-	default:
-		return nil, errors.New("unknown predictor")
 	}
+	return errList, warnings
 }
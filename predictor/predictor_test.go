@@ -1,7 +1,117 @@
 package predictor
 
-import "testing"
+import (
+	"errors"
+	"fmt"
+	"github.com/LL-res/AOM/common/basetype"
+	"github.com/LL-res/AOM/common/consts"
+	"github.com/LL-res/AOM/fake"
+	"github.com/LL-res/AOM/utils"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestNewPredictor(t *testing.T) {
+	tests := []struct {
+		model   string
+		attr    map[string]string
+		wantErr string
+	}{
+		{
+			model: consts.HOLT_WINTER,
+			attr:  map[string]string{"slen": "12", "look_forward": "24", "look_backward": "24"},
+		},
+		{
+			model:   consts.HOLT_WINTER,
+			attr:    map[string]string{"slen": "12", "alpha": "2"},
+			wantErr: "attr[look_forward]: Required value: how many points to predict, attr[look_backward]: Required value",
+		},
+		{
+			model:   consts.HOLT_WINTER,
+			attr:    map[string]string{"slen": "12", "look_forward": "24", "look_backward": "12"},
+			wantErr: "must cover two seasons",
+		},
+		{
+			model: consts.GRU,
+			attr:  map[string]string{"train_size": "1000", "samples": "30"},
+		},
+		{
+			model:   consts.LSTM,
+			attr:    map[string]string{"train_size": "1000", "n_layers": "1", "samples": "30"},
+			wantErr: "attr[samples] 30 needs attr[n_layers] of 2 or more",
+		},
+		{
+			model:   "prophet",
+			wantErr: fmt.Sprintf("unknown model type [prophet], available models: %s, %s, %s, %s, %s", consts.GRU, consts.LSTM, consts.ARIMA, consts.HOLT_WINTER, consts.MODEL_SERVER),
+		},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			pred, err := NewPredictor(Param{
+				WithModelKey:    utils.GetWithModelKey("name$unit$query", tt.model),
+				MetricCollector: &fake.CollectorWorker{},
+				Model:           tt.attr,
+			})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pred.GetType() != tt.model {
+				t.Errorf("got %s, want %s", pred.GetType(), tt.model)
+			}
+		})
+	}
+}
+
+func TestValidateModels(t *testing.T) {
+	models := map[string][]basetype.Model{
+		"b": {{Type: consts.HOLT_WINTER, Attr: map[string]string{"slen": "x", "look_forward": "1", "look_backward": "2"}}},
+		"a": {
+			{Type: consts.GRU, Attr: map[string]string{"train_size": "1000", "layers": "3"}},
+			{Type: "prophet"},
+			{Type: consts.LSTM, Attr: map[string]string{"train_size": "1000", "n_layers": "0"}},
+		},
+	}
+	errList, warnings := ValidateModels(models, field.NewPath("spec", "models"))
+	if len(warnings) != 1 || warnings[0].Field != "spec.models[a][0].attr" {
+		t.Errorf("got warnings %v, want one on the unknown attribute of spec.models[a][0]", warnings)
+	}
+	want := []string{"spec.models[a][1].type", "spec.models[a][2].attr[n_layers]", "spec.models[b][0].attr[slen]"}
+	if len(errList) != len(want) {
+		t.Fatalf("got %v, want errors on %v", errList, want)
+	}
+	for i, err := range errList {
+		if err.Field != want[i] {
+			t.Errorf("got %v, want an error on %s", err, want[i])
+		}
+	}
+}
 
+func TestTraining(t *testing.T) {
+	training := NewTraining()
+	if !training.Start() {
+		t.Fatal("the first training must start")
+	}
+	if training.Start() {
+		t.Fatal("a training must not start while another runs")
+	}
+	now := time.Now()
+	training.Finish(now, errors.New("no data"))
+	if s := training.Snapshot(); s.Running || s.LastError != "no data" || !s.LastTrain.IsZero() {
+		t.Fatalf("got %+v", s)
+	}
+	if !training.Start() {
+		t.Fatal("a training must start once the last one finished")
+	}
+	training.Finish(now, nil)
+	if s := training.Snapshot(); s.Running || s.LastError != "" || !s.LastTrain.Equal(now) {
+		t.Fatalf("got %+v", s)
+	}
 }
@@ -0,0 +1,227 @@
+package supervisor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/LL-res/AOM/log"
+	"go.uber.org/atomic"
+	"net"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// ReadyLine is the line the model server prints on stdout once it listens, the readiness handshake
+	ReadyLine = "READY"
+	// SocketEnv tells the model server the unix socket to listen on
+	SocketEnv = "AOM_MODEL_SOCKET"
+)
+
+// Config of the model server process, the zero durations are replaced by the defaults
+type Config struct {
+	// Command runs the model server, e.g. python3 main.py
+	Command []string
+	// Dir is the working directory, where the server keeps its models
+	Dir    string
+	Socket string
+	// ReadyTimeout bounds the wait for the handshake, default 1m
+	ReadyTimeout time.Duration
+	// HealthInterval is the period of dialing the socket, default 10s, the server is restarted
+	// after MaxFailures failed checks in a row, default 3
+	HealthInterval time.Duration
+	MaxFailures    int
+	// MinBackoff and MaxBackoff bound the wait before a restart, doubled on every crash before ready, default 1s and 1m
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// StopTimeout is how long the server has to exit on SIGTERM before it is killed, default 10s
+	StopTimeout time.Duration
+}
+
+// Supervisor runs one model server shared by the predictors, it is a manager.Runnable
+// and runs on the leader only, where the predictors are
+type Supervisor struct {
+	cfg      Config
+	ready    *atomic.Bool
+	restarts *atomic.Int32
+}
+
+func New(cfg Config) (*Supervisor, error) {
+	if len(cfg.Command) == 0 {
+		return nil, errors.New("no command of the model server")
+	}
+	if cfg.Socket == "" {
+		return nil, errors.New("no socket of the model server")
+	}
+	if cfg.ReadyTimeout <= 0 {
+		cfg.ReadyTimeout = time.Minute
+	}
+	if cfg.HealthInterval <= 0 {
+		cfg.HealthInterval = 10 * time.Second
+	}
+	if cfg.MaxFailures <= 0 {
+		cfg.MaxFailures = 3
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = time.Second
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = time.Minute
+	}
+	if cfg.StopTimeout <= 0 {
+		cfg.StopTimeout = 10 * time.Second
+	}
+	return &Supervisor{cfg: cfg, ready: atomic.NewBool(false), restarts: atomic.NewInt32(0)}, nil
+}
+
+// Ready tells whether the server passed the handshake and its health checks since
+func (s *Supervisor) Ready() bool {
+	return s.ready.Load()
+}
+
+// Restarts counts the restarts of the server
+func (s *Supervisor) Restarts() int {
+	return int(s.restarts.Load())
+}
+
+// Start runs the server until ctx is done, restarting it with backoff when it exits or turns unhealthy
+func (s *Supervisor) Start(ctx context.Context) error {
+	backoff := s.cfg.MinBackoff
+	for {
+		wasReady, err := s.run(ctx)
+		s.ready.Store(false)
+		if ctx.Err() != nil {
+			return nil
+		}
+		// a server that got ready is not crash looping
+		if wasReady {
+			backoff = s.cfg.MinBackoff
+		}
+		log.Logger.Error(err, "model server down, restarting", "backoff", backoff.String())
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		s.restarts.Inc()
+		if backoff *= 2; backoff > s.cfg.MaxBackoff {
+			backoff = s.cfg.MaxBackoff
+		}
+	}
+}
+
+// run starts the server and watches it until it stops, it tells whether the server got ready
+func (s *Supervisor) run(ctx context.Context) (bool, error) {
+	readyCh := make(chan struct{})
+	var once sync.Once
+	cmd := exec.Command(s.cfg.Command[0], s.cfg.Command[1:]...)
+	cmd.Dir = s.cfg.Dir
+	cmd.Env = append(os.Environ(), SocketEnv+"="+s.cfg.Socket)
+	cmd.Stdout = &lineWriter{onLine: func(line string) {
+		if line == ReadyLine {
+			once.Do(func() { close(readyCh) })
+			return
+		}
+		log.Logger.Info("model server", "stdout", line)
+	}}
+	cmd.Stderr = &lineWriter{onLine: func(line string) {
+		log.Logger.Info("model server", "stderr", line)
+	}}
+	if err := cmd.Start(); err != nil {
+		return false, err
+	}
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+	log.Logger.Info("model server started", "command", s.cfg.Command, "pid", cmd.Process.Pid)
+
+	timer := time.NewTimer(s.cfg.ReadyTimeout)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		s.stop(cmd, exited)
+		return false, nil
+	case err := <-exited:
+		return false, fmt.Errorf("exited before ready: %v", err)
+	case <-timer.C:
+		s.stop(cmd, exited)
+		return false, fmt.Errorf("not ready within %s", s.cfg.ReadyTimeout)
+	case <-readyCh:
+	}
+	s.ready.Store(true)
+	log.Logger.Info("model server ready", "socket", s.cfg.Socket)
+
+	ticker := time.NewTicker(s.cfg.HealthInterval)
+	defer ticker.Stop()
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			s.stop(cmd, exited)
+			return true, nil
+		case err := <-exited:
+			return true, fmt.Errorf("exited: %v", err)
+		case <-ticker.C:
+			err := s.check()
+			if err == nil {
+				failures = 0
+				continue
+			}
+			failures++
+			log.Logger.Info("model server health check failed", "failures", failures, "err", err.Error())
+			if failures >= s.cfg.MaxFailures {
+				s.stop(cmd, exited)
+				return true, fmt.Errorf("unhealthy: %w", err)
+			}
+		}
+	}
+}
+
+// check dials the socket, the server drops a connection closed without a request
+func (s *Supervisor) check() error {
+	conn, err := net.DialTimeout("unix", s.cfg.Socket, s.cfg.HealthInterval)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// stop asks the server to exit and kills it after StopTimeout
+func (s *Supervisor) stop(cmd *exec.Cmd, exited chan error) {
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		log.Logger.Error(err, "signal model server failed")
+	}
+	select {
+	case <-exited:
+		return
+	case <-time.After(s.cfg.StopTimeout):
+	}
+	log.Logger.Info("model server did not stop in time, killing it", "pid", cmd.Process.Pid)
+	if err := cmd.Process.Kill(); err != nil {
+		log.Logger.Error(err, "kill model server failed")
+	}
+	<-exited
+}
+
+// lineWriter hands the lines written to it to onLine, cmd.Wait waits for it to be done
+type lineWriter struct {
+	buf    bytes.Buffer
+	onLine func(line string)
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := string(bytes.TrimRight(w.buf.Next(i+1), "\r\n"))
+		w.onLine(line)
+	}
+}
@@ -0,0 +1,131 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"github.com/LL-res/AOM/log"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestHelperProcess is the model server the tests supervise, it runs when the test binary is started by the supervisor
+func TestHelperProcess(t *testing.T) {
+	mode := os.Getenv("HELPER_MODE")
+	if mode == "" {
+		return
+	}
+	if mode == "hang" {
+		// never gets ready
+		time.Sleep(time.Minute)
+		os.Exit(0)
+	}
+	l, err := net.Listen("unix", os.Getenv(SocketEnv))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Println("listening")
+	fmt.Println(ReadyLine)
+	if mode == "crash" {
+		time.Sleep(50 * time.Millisecond)
+		os.Exit(1)
+	}
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			os.Exit(1)
+		}
+		conn.Close()
+	}
+}
+
+func start(t *testing.T, mode string) (*Supervisor, string, func()) {
+	log.Init()
+	socket := filepath.Join(t.TempDir(), "model.sock")
+	os.Setenv("HELPER_MODE", mode)
+	t.Cleanup(func() { os.Unsetenv("HELPER_MODE") })
+	s, err := New(Config{
+		Command:        []string{os.Args[0], "-test.run=TestHelperProcess"},
+		Socket:         socket,
+		ReadyTimeout:   200 * time.Millisecond,
+		HealthInterval: 20 * time.Millisecond,
+		MinBackoff:     10 * time.Millisecond,
+		MaxBackoff:     40 * time.Millisecond,
+		StopTimeout:    time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Start(ctx)
+	}()
+	return s, socket, func() {
+		cancel()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Error(err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("supervisor did not stop")
+		}
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool, what string) {
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSupervisor(t *testing.T) {
+	s, socket, stop := start(t, "serve")
+	waitFor(t, s.Ready, "ready")
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	// the health checks keep passing
+	time.Sleep(100 * time.Millisecond)
+	if !s.Ready() || s.Restarts() != 0 {
+		t.Errorf("got ready %v after %d restarts", s.Ready(), s.Restarts())
+	}
+	stop()
+	if s.Ready() {
+		t.Error("ready after stop")
+	}
+	if _, err := net.Dial("unix", socket); err == nil {
+		t.Error("the server still listens after stop")
+	}
+}
+
+func TestSupervisorRestart(t *testing.T) {
+	for _, mode := range []string{"crash", "hang"} {
+		t.Run(mode, func(t *testing.T) {
+			s, _, stop := start(t, mode)
+			defer stop()
+			waitFor(t, func() bool { return s.Restarts() >= 2 }, "restarts")
+		})
+	}
+}
+
+func TestLineWriter(t *testing.T) {
+	lines := make([]string, 0)
+	w := &lineWriter{onLine: func(line string) { lines = append(lines, line) }}
+	for _, p := range []string{"a\nb", "c\r\n", "\nd"} {
+		w.Write([]byte(p))
+	}
+	if fmt.Sprint(lines) != "[a bc ]" {
+		t.Errorf("got %q", lines)
+	}
+}
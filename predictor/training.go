@@ -0,0 +1,55 @@
+package predictor
+
+import (
+	"sync"
+	"time"
+)
+
+// Training tracks the trainings of one predictor, they run in the background and do not overlap
+type Training struct {
+	mu       sync.Mutex
+	snapshot TrainingSnapshot
+}
+
+type TrainingSnapshot struct {
+	// Running is set from Start until Finish
+	Running bool
+	// the time the latest successful training finished
+	LastTrain time.Time
+	// the error of the latest training, empty once one succeeded
+	LastError string
+}
+
+func NewTraining() *Training {
+	return &Training{}
+}
+
+// Start marks a training as running, false if the one started before has not finished yet
+func (t *Training) Start() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.snapshot.Running {
+		return false
+	}
+	t.snapshot.Running = true
+	return true
+}
+
+// Finish records the outcome of the training started last
+func (t *Training) Finish(now time.Time, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.snapshot.Running = false
+	if err != nil {
+		t.snapshot.LastError = err.Error()
+		return
+	}
+	t.snapshot.LastError = ""
+	t.snapshot.LastTrain = now
+}
+
+func (t *Training) Snapshot() TrainingSnapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.snapshot
+}
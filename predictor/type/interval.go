@@ -0,0 +1,30 @@
+package ptype
+
+import "math"
+
+// Confidence is the attribute of the confidence level of the prediction intervals, shared by the models giving them
+var Confidence = ParamSpec{Name: "confidence", Description: "the confidence level of the prediction intervals", Type: Float, Default: "0.95", Min: Bound(0.5), Max: Bound(0.999)}
+
+// Z returns how many standard deviations a central normal interval at the confidence level spans on each side
+func Z(confidence float64) float64 {
+	return math.Sqrt2 * math.Erfinv(confidence)
+}
+
+// Quantile returns the forecast at quantile q in (0,1), reading the prediction intervals as normal ones,
+// it is the point forecast when the model gives no intervals
+func (r PredictResult) Quantile(q float64) []float64 {
+	if q <= 0 || q >= 1 || r.Confidence <= 0 || len(r.Lower) != len(r.PredictMetric) || len(r.Upper) != len(r.PredictMetric) {
+		return r.PredictMetric
+	}
+	z, zq := Z(r.Confidence), math.Sqrt2*math.Erfinv(2*q-1)
+	res := make([]float64, len(r.PredictMetric))
+	for i, m := range r.PredictMetric {
+		// the bounds may be clamped, so the side of the quantile gives the spread
+		sigma := (r.Upper[i] - m) / z
+		if q < 0.5 {
+			sigma = (m - r.Lower[i]) / z
+		}
+		res[i] = m + zq*sigma
+	}
+	return res
+}
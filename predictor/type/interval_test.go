@@ -0,0 +1,50 @@
+package ptype
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestPredictResultQuantile(t *testing.T) {
+	z := Z(0.95)
+	tests := []struct {
+		res  PredictResult
+		q    float64
+		want []float64
+	}{
+		{
+			// no intervals
+			res:  PredictResult{PredictMetric: []float64{1, 2}},
+			q:    0.9,
+			want: []float64{1, 2},
+		},
+		{
+			res:  PredictResult{PredictMetric: []float64{10, 20}, Lower: []float64{10 - z, 20 - 2*z}, Upper: []float64{10 + z, 20 + 2*z}, Confidence: 0.95},
+			q:    0.5,
+			want: []float64{10, 20},
+		},
+		{
+			// the 97.5% quantile is the upper bound of the 95% interval
+			res:  PredictResult{PredictMetric: []float64{10, 20}, Lower: []float64{10 - z, 20 - 2*z}, Upper: []float64{10 + z, 20 + 2*z}, Confidence: 0.95},
+			q:    0.975,
+			want: []float64{10 + z, 20 + 2*z},
+		},
+		{
+			// the lower bound is clamped, the upper one still gives the spread
+			res:  PredictResult{PredictMetric: []float64{1}, Lower: []float64{0}, Upper: []float64{1 + 2*z}, Confidence: 0.95},
+			q:    0.8413447,
+			want: []float64{3},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			got := tt.res.Quantile(tt.q)
+			for j := range tt.want {
+				if math.Abs(got[j]-tt.want[j]) > 1e-4 {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package ptype
+
+import (
+	"fmt"
+	"github.com/LL-res/AOM/collector"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Factory builds the predictor of a model from the attributes parsed by its schema
+type Factory func(worker collector.MetricCollector, values Values, withModelKey string) (Predictor, error)
+
+// Model is what a model package registers under its type name
+type Model struct {
+	Factory Factory
+	Schema  Schema
+}
+
+var (
+	models   = make(map[string]Model)
+	modelsMu sync.RWMutex
+)
+
+// Register makes a model available under name, it is meant to be called in the init of the model package
+// and panics when name is taken or the factory is missing
+func Register(name string, model Model) {
+	modelsMu.Lock()
+	defer modelsMu.Unlock()
+	if model.Factory == nil {
+		panic(fmt.Sprintf("register model %s: nil factory", name))
+	}
+	if _, ok := models[name]; ok {
+		panic(fmt.Sprintf("register model %s: registered twice", name))
+	}
+	models[name] = model
+}
+
+// Models returns the registered type names, sorted
+func Models() []string {
+	modelsMu.RLock()
+	defer modelsMu.RUnlock()
+	res := make([]string, 0, len(models))
+	for name := range models {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Lookup returns the model registered under name
+func Lookup(name string) (Model, error) {
+	modelsMu.RLock()
+	model, ok := models[name]
+	modelsMu.RUnlock()
+	if !ok {
+		return Model{}, fmt.Errorf("unknown model type [%s], available models: %s", name, strings.Join(Models(), ", "))
+	}
+	return model, nil
+}
+
+// Validate checks the attributes of a model of type modelType, the webhooks and the controller share it,
+// the unknown attributes are returned as warnings
+func Validate(modelType string, attr map[string]string, fldPath *field.Path) (errList, warnings field.ErrorList) {
+	model, err := Lookup(modelType)
+	if err != nil {
+		return field.ErrorList{field.NotSupported(fldPath.Child("type"), modelType, Models())}, nil
+	}
+	_, errList = model.Schema.Validate(attr, fldPath.Child("attr"))
+	return errList, model.Schema.Unknown(attr, fldPath.Child("attr"))
+}
+
+// New builds the predictor of the model, attr is validated by the schema first
+func (m Model) New(worker collector.MetricCollector, attr map[string]string, withModelKey string) (Predictor, error) {
+	values, err := m.Schema.Parse(attr)
+	if err != nil {
+		return nil, err
+	}
+	return m.Factory(worker, values, withModelKey)
+}
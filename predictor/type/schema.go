@@ -0,0 +1,254 @@
+package ptype
+
+import (
+	"fmt"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParamType is the type the string value of an attribute is parsed into
+type ParamType string
+
+const (
+	Int      ParamType = "integer"
+	Float    ParamType = "number"
+	Bool     ParamType = "boolean"
+	String   ParamType = "string"
+	Duration ParamType = "duration"
+)
+
+// the patterns the values of the types match, put into the json schema since attributes are strings in the CRD
+var patterns = map[ParamType]string{
+	Int:      `^[-+]?[0-9]+$`,
+	Float:    `^[-+]?([0-9]+\.?[0-9]*|\.[0-9]+)([eE][-+]?[0-9]+)?$`,
+	Bool:     `^(true|false)$`,
+	Duration: `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`,
+}
+
+// ParamSpec describes an attribute of a model
+type ParamSpec struct {
+	Name        string
+	Description string
+	Type        ParamType
+	// Required attributes must be set in spec, they have no default
+	Required bool
+	// Default is used when the attribute is left out
+	Default string
+	// Min and Max bound numbers and durations, in seconds for the latter
+	Min, Max *float64
+	// Enum lists the values allowed, if any
+	Enum []string
+}
+
+// Bound returns a pointer to v, for Min and Max
+func Bound(v float64) *float64 {
+	return &v
+}
+
+// Schema is the list of attributes a model understands
+type Schema []ParamSpec
+
+// Values holds the attributes parsed by a schema
+type Values map[string]interface{}
+
+func (v Values) Int(name string) int {
+	res, _ := v[name].(int)
+	return res
+}
+
+func (v Values) Float(name string) float64 {
+	res, _ := v[name].(float64)
+	return res
+}
+
+func (v Values) Bool(name string) bool {
+	res, _ := v[name].(bool)
+	return res
+}
+
+func (v Values) String(name string) string {
+	res, _ := v[name].(string)
+	return res
+}
+
+func (v Values) Duration(name string) time.Duration {
+	res, _ := v[name].(time.Duration)
+	return res
+}
+
+// Has tells whether the attribute was set or has a default
+func (v Values) Has(name string) bool {
+	_, ok := v[name]
+	return ok
+}
+
+// Validate parses attr, filling in the defaults, and reports every invalid or missing attribute at once,
+// unknown attributes are ignored as they were before the schemas, see Unknown
+func (s Schema) Validate(attr map[string]string, fldPath *field.Path) (Values, field.ErrorList) {
+	var errList field.ErrorList
+	values := make(Values, len(s))
+	for _, p := range s {
+		raw, ok := attr[p.Name]
+		if !ok {
+			if p.Required {
+				errList = append(errList, field.Required(fldPath.Key(p.Name), p.Description))
+				continue
+			}
+			if p.Default == "" {
+				continue
+			}
+			raw = p.Default
+		}
+		value, err := p.parse(raw, fldPath.Key(p.Name))
+		if err != nil {
+			errList = append(errList, err)
+			continue
+		}
+		values[p.Name] = value
+	}
+	return values, errList
+}
+
+// Unknown reports the attributes the schema does not know, they are warnings rather than errors
+// since existing objects may carry them, e.g. a misspelt name
+func (s Schema) Unknown(attr map[string]string, fldPath *field.Path) field.ErrorList {
+	known := make(map[string]struct{}, len(s))
+	for _, p := range s {
+		known[p.Name] = struct{}{}
+	}
+	unknown := make([]string, 0)
+	for name := range attr {
+		if _, ok := known[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	var warnings field.ErrorList
+	for _, name := range unknown {
+		warnings = append(warnings, field.NotSupported(fldPath, name, s.names()))
+	}
+	return warnings
+}
+
+// Parse is Validate for the callers outside of an object, the errors are aggregated into one
+func (s Schema) Parse(attr map[string]string) (Values, error) {
+	values, errList := s.Validate(attr, field.NewPath("attr"))
+	if len(errList) != 0 {
+		return nil, errList.ToAggregate()
+	}
+	return values, nil
+}
+
+func (s Schema) names() []string {
+	res := make([]string, 0, len(s))
+	for _, p := range s {
+		res = append(res, p.Name)
+	}
+	return res
+}
+
+func (p ParamSpec) parse(raw string, fldPath *field.Path) (interface{}, *field.Error) {
+	if len(p.Enum) != 0 {
+		found := false
+		for _, e := range p.Enum {
+			found = found || e == raw
+		}
+		if !found {
+			return nil, field.NotSupported(fldPath, raw, p.Enum)
+		}
+	}
+	var value interface{}
+	var number float64
+	switch p.Type {
+	case Int:
+		v, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, field.Invalid(fldPath, raw, "must be an integer")
+		}
+		value, number = v, float64(v)
+	case Float:
+		v, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil {
+			return nil, field.Invalid(fldPath, raw, "must be a number")
+		}
+		// NaN passes the bounds, it compares false to everything
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, field.Invalid(fldPath, raw, "must be a finite number")
+		}
+		value, number = v, v
+	case Bool:
+		v, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, field.Invalid(fldPath, raw, "must be true or false")
+		}
+		value = v
+	case Duration:
+		v, err := time.ParseDuration(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, field.Invalid(fldPath, raw, "must be a duration, e.g. 1m")
+		}
+		value, number = v, v.Seconds()
+	default:
+		value = raw
+	}
+	if p.Min != nil && number < *p.Min {
+		return nil, field.Invalid(fldPath, raw, fmt.Sprintf("must be at least %s", p.bound(*p.Min)))
+	}
+	if p.Max != nil && number > *p.Max {
+		return nil, field.Invalid(fldPath, raw, fmt.Sprintf("must be at most %s", p.bound(*p.Max)))
+	}
+	return value, nil
+}
+
+func (p ParamSpec) bound(v float64) string {
+	if p.Type == Duration {
+		return time.Duration(v * float64(time.Second)).String()
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// JSONSchema describes attr for the CRD, the values stay strings there, so the types are checked by pattern
+// and the bounds only documented, unknown attributes are allowed as Validate ignores them
+func (s Schema) JSONSchema() apiextensionsv1.JSONSchemaProps {
+	res := apiextensionsv1.JSONSchemaProps{
+		Type:       "object",
+		Properties: make(map[string]apiextensionsv1.JSONSchemaProps, len(s)),
+	}
+	for _, p := range s {
+		prop := apiextensionsv1.JSONSchemaProps{
+			Type:        "string",
+			Description: p.describe(),
+			Pattern:     patterns[p.Type],
+		}
+		for _, e := range p.Enum {
+			prop.Enum = append(prop.Enum, apiextensionsv1.JSON{Raw: []byte(strconv.Quote(e))})
+		}
+		if p.Default != "" {
+			prop.Default = &apiextensionsv1.JSON{Raw: []byte(strconv.Quote(p.Default))}
+		}
+		if p.Required {
+			res.Required = append(res.Required, p.Name)
+		}
+		res.Properties[p.Name] = prop
+	}
+	return res
+}
+
+func (p ParamSpec) describe() string {
+	res := p.Description
+	if p.Type != "" && p.Type != String {
+		res += fmt.Sprintf(", %s", p.Type)
+	}
+	if p.Min != nil {
+		res += fmt.Sprintf(", at least %s", p.bound(*p.Min))
+	}
+	if p.Max != nil {
+		res += fmt.Sprintf(", at most %s", p.bound(*p.Max))
+	}
+	return res
+}
@@ -0,0 +1,113 @@
+package ptype
+
+import (
+	"fmt"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSchema = Schema{
+	{Name: "window", Type: Int, Required: true, Min: Bound(1)},
+	{Name: "alpha", Type: Float, Default: "0.5", Min: Bound(0), Max: Bound(1)},
+	{Name: "debug", Type: Bool, Default: "false"},
+	{Name: "resolution", Type: Duration, Enum: []string{"1m", "10m"}},
+	{Name: "timeout", Type: Duration, Default: "30s", Max: Bound(60)},
+	{Name: "address", Type: String},
+}
+
+func TestSchemaValidate(t *testing.T) {
+	tests := []struct {
+		attr map[string]string
+		want Values
+		// the fields of the errors expected, all reported at once
+		wantErr []string
+	}{
+		{
+			attr: map[string]string{"window": "10"},
+			want: Values{"window": 10, "alpha": 0.5, "debug": false, "timeout": 30 * time.Second},
+		},
+		{
+			attr: map[string]string{"window": "3", "alpha": "1", "debug": "true", "resolution": "10m", "address": "/tmp/s"},
+			want: Values{"window": 3, "alpha": 1.0, "debug": true, "resolution": 10 * time.Minute, "timeout": 30 * time.Second, "address": "/tmp/s"},
+		},
+		{
+			attr:    map[string]string{"alpha": "1.5", "debug": "yes", "resolution": "5m", "timeout": "2m", "windows": "3"},
+			wantErr: []string{"attr[window]", "attr[alpha]", "attr[debug]", "attr[resolution]", "attr[timeout]"},
+		},
+		{
+			attr:    map[string]string{"window": "0.5"},
+			wantErr: []string{"attr[window]"},
+		},
+		{
+			attr:    map[string]string{"window": "1", "alpha": "NaN"},
+			wantErr: []string{"attr[alpha]"},
+		},
+		{
+			attr:    map[string]string{"window": "1", "alpha": "+Inf"},
+			wantErr: []string{"attr[alpha]"},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			got, errList := testSchema.Validate(tt.attr, field.NewPath("attr"))
+			if len(errList) != len(tt.wantErr) {
+				t.Fatalf("got errors %v, want on %v", errList, tt.wantErr)
+			}
+			for j, err := range errList {
+				if err.Field != tt.wantErr[j] {
+					t.Errorf("got error %v, want on %s", err, tt.wantErr[j])
+				}
+			}
+			if len(tt.wantErr) != 0 {
+				return
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemaUnknown(t *testing.T) {
+	warnings := testSchema.Unknown(map[string]string{"window": "1", "windows": "3", "beta": "1"}, field.NewPath("attr"))
+	if len(warnings) != 2 || warnings[0].BadValue != "beta" || warnings[1].BadValue != "windows" {
+		t.Fatalf("got %v, want warnings on beta and windows", warnings)
+	}
+	if _, err := testSchema.Parse(map[string]string{"window": "1", "windows": "3"}); err != nil {
+		t.Errorf("unknown attributes must be ignored, got %v", err)
+	}
+}
+
+func TestSchemaParse(t *testing.T) {
+	_, err := testSchema.Parse(map[string]string{"alpha": "2"})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"attr[window]: Required value", "attr[alpha]: Invalid value: \"2\": must be at most 1"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("got %v, want it to contain %s", err, want)
+		}
+	}
+}
+
+func TestSchemaJSONSchema(t *testing.T) {
+	s := testSchema.JSONSchema()
+	if len(s.Properties) != len(testSchema) || len(s.Required) != 1 || s.Required[0] != "window" {
+		t.Fatalf("got %+v", s)
+	}
+	if s.AdditionalProperties != nil {
+		t.Error("unknown attributes must be allowed")
+	}
+	window := s.Properties["window"]
+	if window.Type != "string" || window.Pattern != patterns[Int] || !strings.Contains(window.Description, "at least 1") {
+		t.Errorf("got %+v", window)
+	}
+	if timeout := s.Properties["timeout"]; string(timeout.Default.Raw) != `"30s"` || !strings.Contains(timeout.Description, "at most 1m0s") {
+		t.Errorf("got %+v", timeout)
+	}
+	if resolution := s.Properties["resolution"]; len(resolution.Enum) != 2 {
+		t.Errorf("got %+v", resolution)
+	}
+}
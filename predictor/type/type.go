@@ -1,6 +1,9 @@
 package ptype
 
-import "github.com/LL-res/AOM/collector"
+import (
+	"context"
+	"github.com/LL-res/AOM/collector"
+)
 
 type Base struct {
 	MetricHistory []collector.Metric // 存储着全部
@@ -10,4 +13,28 @@ type PredictResult struct {
 	StartMetric   float64
 	Loss          float64
 	PredictMetric []float64
+	// Lower and Upper bound PredictMetric point by point at the Confidence level, nil when the model gives no intervals
+	Lower      []float64
+	Upper      []float64
+	Confidence float64
+}
+
+// Predictor makes predictions on the metrics of a worker with a model
+type Predictor interface {
+	Predict(ctx context.Context) (PredictResult, error)
+	GetType() string
+	Train(ctx context.Context) error
+	Key() string
+}
+
+// Reporter is implemented by the predictors with details worth showing in status,
+// e.g. the parameters fitted by Train
+type Reporter interface {
+	Report() map[string]string
+}
+
+// Removable is implemented by the predictors holding state outside of themselves,
+// Remove is called once the predictor is deleted
+type Removable interface {
+	Remove()
 }
@@ -2,6 +2,7 @@ package scheduler
 
 import (
 	"context"
+	automationv1 "github.com/LL-res/AOM/api/v1"
 	"github.com/LL-res/AOM/common/errs"
 	"github.com/LL-res/AOM/common/store"
 	"github.com/LL-res/AOM/log"
@@ -9,6 +10,7 @@ import (
 	"github.com/LL-res/AOM/scaler"
 	"github.com/LL-res/AOM/utils"
 	"k8s.io/apimachinery/pkg/types"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
@@ -60,6 +62,11 @@ func (s *Scheduler) Run(ctx context.Context) {
 	for _ = range ticker.C {
 		waitGroup := sync.WaitGroup{}
 		hide := store.GetHide(s.Name)
+		stale := hide.StaleMetrics(time.Now())
+		if len(stale) != 0 && hide.StalePolicy != automationv1.StalePolicyIgnore {
+			log.Logger.Info("scaling blocked by stale metrics", "metrics", keys(stale))
+			continue
+		}
 		hide.PredictorMap.Lock()
 		scr := hide.Scaler
 		ResChan := make(chan ResPair, len(hide.PredictorMap.Data))
@@ -77,6 +84,11 @@ func (s *Scheduler) Run(ctx context.Context) {
 				log.Logger.Error(err, "")
 				continue
 			}
+			// the stale metrics are left out of the decision
+			if _, ok := stale[utils.GetNoModelKey(withModelKey)]; ok {
+				log.Logger.Info("skip predictor of stale metric", "predictor", withModelKey)
+				continue
+			}
 			// 进行预测
 			waitGroup.Add(1)
 			go func(withModelKey string, pred predictor.Predictor, scr *scaler.Scaler) {
@@ -95,7 +107,17 @@ func (s *Scheduler) Run(ctx context.Context) {
 					log.Logger.Error(err, "strconv failed")
 					return
 				}
-				modelReplica, err := scr.GetModelReplica(pResult.PredictMetric, pResult.StartMetric, scaler.UnderThreshold, targetVal)
+				// size on the quantile of the metric, when it asks for one
+				forecast := pResult.PredictMetric
+				if metric.Quantile != "" {
+					q, err := strconv.ParseFloat(metric.Quantile, 64)
+					if err != nil {
+						log.Logger.Error(err, "strconv failed")
+						return
+					}
+					forecast = pResult.Quantile(q)
+				}
+				modelReplica, err := scr.GetModelReplica(forecast, pResult.StartMetric, scaler.UnderThreshold, targetVal)
 				if err != nil {
 					log.Logger.Error(err, "get model replica failed", "key", withModelKey)
 					return
@@ -111,11 +133,25 @@ func (s *Scheduler) Run(ctx context.Context) {
 				if !(err != nil || lastTime.Add(time.Second*time.Duration(model.UpdateInterval)).Before(time.Now())) {
 					continue
 				}
+				training, err := hide.TrainingMap.Load(withModelKey)
+				if err != nil {
+					log.Logger.Error(err, "")
+					continue
+				}
 				// train use asynchronous,so it won`t block the process for too long
-				if err := pred.Train(ctx); err != nil {
-					log.Logger.Error(err, "train model failed", "key", withModelKey)
+				if !training.Start() {
+					log.Logger.Info("the last training has not finished yet", "predictor", withModelKey)
 					continue
 				}
+				go func(withModelKey string, pred predictor.Predictor, training *predictor.Training) {
+					err := pred.Train(ctx)
+					training.Finish(time.Now(), err)
+					if err != nil {
+						log.Logger.Error(err, "train model failed", "key", withModelKey)
+						return
+					}
+					hide.TrainHistory.Store(withModelKey, time.Now())
+				}(withModelKey, pred, training)
 			}
 		}
 		hide.PredictorMap.Unlock()
@@ -172,6 +208,15 @@ func (s *Scheduler) Run(ctx context.Context) {
 	}
 }
 
+func keys(m map[string]struct{}) []string {
+	res := make([]string, 0, len(m))
+	for k := range m {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
+
 //// predictors : 每个metric指标对应一组predictor，predictors中包含一个aom实例所拥有的全部的predictor，并按所属metric不同，分为不同的组
 //func (s *Scheduler) HandlePredictors(ctx context.Context, predictors map[automationv1.Metric][]predictor.Predictor) error {
 //	// 何时预测，何时更新的信息记录在model中